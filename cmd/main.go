@@ -1,58 +1,291 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/geedotrar/mygram/internal/config"
+	"github.com/geedotrar/mygram/internal/event"
 	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/imaging"
 	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/metrics"
+	"github.com/geedotrar/mygram/internal/middleware"
 	"github.com/geedotrar/mygram/internal/repository"
 	"github.com/geedotrar/mygram/internal/router"
 	"github.com/geedotrar/mygram/internal/service"
+	"github.com/geedotrar/mygram/internal/storage"
+	"github.com/geedotrar/mygram/internal/stream"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/helper"
+	"github.com/geedotrar/mygram/pkg/logger"
 
 	"github.com/gin-gonic/gin"
-
-	"github.com/joho/godotenv"
 )
 
 func main() {
-
-	server()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		// there is no logger to report it with yet
+		fmt.Fprintf(os.Stderr, "mygram: cannot load the configuration: %v\n", err)
+		os.Exit(1)
+	}
+	// invalid log settings fall back to the defaults until Validate
+	// reports them
+	slog.SetDefault(logger.New(os.Stderr, cfg.Log.Format, cfg.Log.Level))
+	if len(args) > 0 && args[0] == "migrate" {
+		migrate(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 {
+		slog.Error("unknown command", "command", args[0])
+		os.Exit(2)
+	}
+	server(cfg)
 }
 
-func server() {
-	err := godotenv.Load("../.env")
+func server(cfg config.Config) {
+	if err := cfg.Validate(); err != nil {
+		fatal("invalid configuration", err)
+	}
+	flushTraces, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		fatal("cannot set up tracing", err)
+	}
+	g := gin.New()
+	// let the services reach the request logger and span through the gin
+	// context
+	g.ContextWithFallback = true
+	g.Use(middleware.Tracing(), middleware.RequestLogger(slog.Default()))
+	if cfg.Features.Metrics {
+		g.Use(middleware.Metrics())
 	}
-	g := gin.Default()
-	g.Use(gin.Recovery())
+	// inside the middlewares above, so they see a panic as a 500
+	g.Use(middleware.Recover(), middleware.RenderErrors())
+
+	keys, err := helper.LoadKeySet(cfg.JWT.Keys, cfg.JWT.Secret, cfg.JWT.ActiveKID)
+	if err != nil {
+		fatal("cannot load JWT signing keys", err)
+	}
+	policy := helper.ClaimPolicy{
+		Issuer:   cfg.JWT.Issuer,
+		Audience: cfg.JWT.Audience,
+		Leeway:   cfg.JWT.Leeway,
+	}
+	wellKnownGroup := g.Group("/.well-known")
+	keyHdl := handler.NewKeyHandler(keys)
+	keyRouter := router.NewKeyRouter(wellKnownGroup, keyHdl)
+	keyRouter.Mount()
 
 	usersGroup := g.Group("/users")
 
-	gorm := infrastructure.NewGormPostgres()
+	gorm, err := infrastructure.NewGormPostgres(cfg.Database)
+	if err != nil {
+		fatal("cannot connect to the database", err)
+	}
+	if cfg.Features.AutoMigrate {
+		migrateUp(gorm)
+	}
+	bus := event.NewBus(cfg.Workers.EventWorkers, cfg.Workers.EventQueueSize)
 	userRepo := repository.NewUserQuery(gorm)
-	userSvc := service.NewUserService(userRepo)
+	sessionRepo := repository.NewSessionQuery(gorm)
+	entityRepo := repository.NewEntityQuery(gorm)
+	userSvc := service.NewUserService(userRepo, sessionRepo, entityRepo, keys, policy, bus)
+	authMdw := middleware.NewAuthMiddleware(userSvc, keys, policy)
 	userHdl := handler.NewUserHandler(userSvc)
-	userRouter := router.NewUserRouter(usersGroup, userHdl)
+	userRouter := router.NewUserRouter(usersGroup, userHdl, authMdw)
 	userRouter.Mount()
 	photosGroup := g.Group("/photos")
+	store, err := storage.NewStorage(storage.Config{
+		Driver:    cfg.Storage.Driver,
+		LocalRoot: cfg.Storage.LocalRoot,
+		S3: storage.S3Config{
+			Endpoint:        cfg.Storage.S3Endpoint,
+			Region:          cfg.Storage.S3Region,
+			Bucket:          cfg.Storage.S3Bucket,
+			AccessKeyID:     cfg.Storage.S3AccessKeyID,
+			SecretAccessKey: cfg.Storage.S3SecretAccessKey,
+		},
+	})
+	if err != nil {
+		fatal("cannot initialize photo storage", err)
+	}
+	imagePool := imaging.NewPool(cfg.Workers.ImageWorkers, cfg.Workers.ImageQueueSize)
 	photoRepo := repository.NewPhotoQuery(gorm)
-	photoSvc := service.NewPhotoService(photoRepo, userRepo)
-	photoHdl := handler.NewPhotoHandler(photoSvc)
-	photoRouter := router.NewPhotoRouter(photosGroup, photoHdl)
+	likeRepo := repository.NewLikeQuery(gorm)
+	photoSvc := service.NewPhotoService(photoRepo, userRepo, likeRepo, entityRepo, store, imagePool, bus)
+	imagePool.Start(photoSvc.ProcessPhotoVariants)
+	if err := photoSvc.EnqueuePendingPhotos(context.Background()); err != nil {
+		slog.Error("cannot requeue pending photos", "error", err)
+	}
+	photoHdl := handler.NewPhotoHandler(photoSvc, cfg.Server.MaxUploadBytes)
+	photoRouter := router.NewPhotoRouter(photosGroup, photoHdl, authMdw)
 	photoRouter.Mount()
+	feedGroup := g.Group("/feed")
+	feedRouter := router.NewFeedRouter(feedGroup, photoHdl, authMdw)
+	feedRouter.Mount()
+	tagsGroup := g.Group("/tags")
+	tagRouter := router.NewTagRouter(tagsGroup, photoHdl, authMdw)
+	tagRouter.Mount()
 	commentsGroup := g.Group("/comments")
 	commentRepo := repository.NewCommentQuery(gorm)
-	commentSvc := service.NewCommentService(commentRepo, userRepo, photoRepo)
+	commentSvc := service.NewCommentService(commentRepo, userRepo, photoRepo, likeRepo, entityRepo, bus)
 	commentHdl := handler.NewCommentHandler(commentSvc)
-	commentRouter := router.NewCommentRouter(commentsGroup, commentHdl)
+	commentRouter := router.NewCommentRouter(commentsGroup, commentHdl, authMdw)
 	commentRouter.Mount()
+	likesGroup := g.Group("")
+	likeSvc := service.NewLikeService(likeRepo, photoRepo, commentRepo, bus)
+	likeHdl := handler.NewLikeHandler(likeSvc)
+	likeRouter := router.NewLikeRouter(likesGroup, likeHdl, authMdw)
+	likeRouter.Mount()
 	socialMediasGroup := g.Group("/socialmedias")
 	socialMediaRepo := repository.NewSocialMediaQuery(gorm)
 	socialMediaSvc := service.NewSocialMediaService(socialMediaRepo, userRepo)
 	socialMediaHdl := handler.NewSocialMediaHandler(socialMediaSvc)
-	socialMediaRouter := router.NewSocialMediaRouter(socialMediasGroup, socialMediaHdl)
+	socialMediaRouter := router.NewSocialMediaRouter(socialMediasGroup, socialMediaHdl, authMdw)
 	socialMediaRouter.Mount()
+	if cfg.Features.Search {
+		searchGroup := g.Group("/search")
+		searchRepo := repository.NewSearchQuery(gorm)
+		searchSvc := service.NewSearchService(searchRepo, likeRepo, entityRepo)
+		searchHdl := handler.NewSearchHandler(searchSvc)
+		searchRouter := router.NewSearchRouter(searchGroup, searchHdl, authMdw)
+		searchRouter.Mount()
+	}
+	notificationsGroup := g.Group("/notifications")
+	notificationRepo := repository.NewNotificationQuery(gorm)
+	notificationSvc := service.NewNotificationService(notificationRepo, bus)
+	notificationHdl := handler.NewNotificationHandler(notificationSvc)
+	notificationRouter := router.NewNotificationRouter(notificationsGroup, notificationHdl, authMdw)
+	notificationRouter.Mount()
+	bus.Subscribe(notificationSvc.HandleEvent)
+	migrator := newMigrator(gorm)
+	checks := []handler.ReadinessCheck{
+		{Name: "database", Check: gorm.Ping},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err == nil && pending > 0 {
+				err = fmt.Errorf("%d migrations pending", pending)
+			}
+			return err
+		}},
+		{Name: "image_pool", Check: running(imagePool.Running)},
+		{Name: "event_bus", Check: running(bus.Running)},
+	}
+	var hub *stream.Hub
+	if cfg.Features.Stream {
+		streamGroup := g.Group("/stream")
+		hub = stream.NewHub(stream.NewMemoryBroker())
+		if err := hub.Start(); err != nil {
+			fatal("cannot start stream hub", err)
+		}
+		streamSvc := service.NewStreamService(hub, commentSvc, notificationSvc)
+		streamHdl := handler.NewStreamHandler(streamSvc)
+		streamRouter := router.NewStreamRouter(streamGroup, streamHdl, authMdw)
+		streamRouter.Mount()
+		bus.Subscribe(streamSvc.HandleEvent)
+		checks = append(checks, handler.ReadinessCheck{Name: "stream_hub", Check: running(hub.Running)})
+	}
+	bus.Start()
+	healthGroup := g.Group("")
+	healthHdl := handler.NewHealthHandler(checks...)
+	healthRouter := router.NewHealthRouter(healthGroup, healthHdl)
+	healthRouter.Mount()
+	if cfg.Features.Metrics {
+		metricsGroup := g.Group("")
+		metricsRouter := router.NewMetricsRouter(metricsGroup, metrics.Handler())
+		metricsRouter.Mount()
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           g,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	if hub != nil {
+		// open streams never finish on their own, so end them as soon as
+		// the server stops accepting connections
+		srv.RegisterOnShutdown(hub.Stop)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	failed := false
+	select {
+	case err := <-serveErr:
+		slog.Error("cannot serve HTTP", "error", err)
+		failed = true
+	case <-ctx.Done():
+		slog.Info("shutting down, send the signal again to force it")
+	}
+	// a second signal kills the process right away
+	stop()
+
+	if !shutdown(srv, imagePool, bus, gorm, flushTraces, cfg.Server.ShutdownTimeout) || failed {
+		os.Exit(1)
+	}
+}
+
+// shutdown drains the in-flight requests, then stops the workers, closes
+// the database and flushes the traces, all within timeout. It reports
+// whether every step finished cleanly.
+func shutdown(srv *http.Server, imagePool *imaging.Pool, bus *event.Bus, gorm infrastructure.GormPostgres, flushTraces func(ctx context.Context) error, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	g.Run(":3000")
+	clean := true
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("cannot drain HTTP requests", "error", err)
+		clean = false
+	}
+	// photos left unprocessed stay pending and are requeued on the next start
+	if err := imagePool.Stop(ctx); err != nil {
+		slog.Error("cannot stop the image pool", "error", err)
+		clean = false
+	}
+	if err := bus.Stop(ctx); err != nil {
+		slog.Error("cannot stop the event bus", "error", err)
+		clean = false
+	}
+	if err := gorm.Close(); err != nil {
+		slog.Error("cannot close the database", "error", err)
+		clean = false
+	}
+	if err := flushTraces(ctx); err != nil {
+		slog.Error("cannot flush traces", "error", err)
+		clean = false
+	}
+	return clean
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// running turns the Running method of a background worker into a readiness
+// check.
+func running(isRunning func() bool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !isRunning() {
+			return errors.New("not running")
+		}
+		return nil
+	}
 }
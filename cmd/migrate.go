@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/geedotrar/mygram/internal/config"
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/migration"
+)
+
+const migrateUsage = `usage: mygram [flags] migrate <command>
+
+commands:
+  up                apply every pending migration
+  down [-steps n]   revert the last n applied migrations (default 1)
+  status            list the migrations and whether they are applied
+  create [-dir d] <name>
+                    add blank up and down scripts for a new migration
+`
+
+func migrate(cfg config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		migrateUp(connect(cfg))
+	case "down":
+		flags := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		flags.Parse(args[1:])
+		if *steps < 1 {
+			fatal("invalid -steps", fmt.Errorf("%d is not positive", *steps))
+		}
+		reverted, err := newMigrator(connect(cfg)).Down(ctx, *steps)
+		for _, m := range reverted {
+			slog.Info("reverted migration", "migration", m.String())
+		}
+		if err != nil {
+			fatal("cannot revert migrations", err)
+		}
+		if len(reverted) == 0 {
+			slog.Info("no applied migrations")
+		}
+	case "status":
+		statuses, err := newMigrator(connect(cfg)).Status(ctx)
+		if err != nil {
+			fatal("cannot read the migration status", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	case "create":
+		flags := flag.NewFlagSet("create", flag.ExitOnError)
+		dir := flags.String("dir", migration.DEFAULT_DIR, "directory holding the migration scripts")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		up, down, err := migration.Create(*dir, flags.Arg(0))
+		if err != nil {
+			fatal("cannot create the migration", err)
+		}
+		slog.Info("created migration", "up", up, "down", down)
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// migrateUp applies the pending migrations, also run on startup when the
+// auto migrate feature is on.
+func migrateUp(gorm infrastructure.GormPostgres) {
+	applied, err := newMigrator(gorm).Up(context.Background())
+	for _, m := range applied {
+		slog.Info("applied migration", "migration", m.String())
+	}
+	if err != nil {
+		fatal("cannot apply migrations", err)
+	}
+	if len(applied) == 0 {
+		slog.Info("no pending migrations")
+	}
+}
+
+func connect(cfg config.Config) infrastructure.GormPostgres {
+	if err := cfg.Database.Validate(); err != nil {
+		fatal("invalid configuration", err)
+	}
+	gorm, err := infrastructure.NewGormPostgres(cfg.Database)
+	if err != nil {
+		fatal("cannot connect to the database", err)
+	}
+	return gorm
+}
+
+func newMigrator(gorm infrastructure.GormPostgres) *migration.Migrator {
+	migrator, err := migration.NewMigrator(gorm.GetConnection())
+	if err != nil {
+		fatal("cannot load migrations", err)
+	}
+	return migrator
+}
@@ -0,0 +1,87 @@
+// Package apperror defines the errors the services report to clients. Each
+// one has a kind, which decides the HTTP status, and a stable code clients
+// can match on; any other error is treated as internal and never shown.
+package apperror
+
+import "errors"
+
+// The kinds of error. Repositories return ErrNotFound as is when a row does
+// not exist; services wrap the kinds in an Error with a more precise code.
+var (
+	ErrNotFound     = errors.New("resource not found")
+	ErrForbidden    = errors.New("you are not allowed to access this resource")
+	ErrConflict     = errors.New("resource already exists")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrTooLarge     = errors.New("request is too large")
+	ErrUnsupported  = errors.New("unsupported media type")
+)
+
+const (
+	CODE_NOT_FOUND    = "not_found"
+	CODE_FORBIDDEN    = "forbidden"
+	CODE_CONFLICT     = "conflict"
+	CODE_VALIDATION   = "validation_failed"
+	CODE_UNAUTHORIZED = "unauthorized"
+	CODE_TOO_LARGE    = "too_large"
+	CODE_UNSUPPORTED  = "unsupported_media_type"
+	CODE_INTERNAL     = "internal_error"
+)
+
+// FieldError tells which field of the request is invalid and why.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error is an error meant for the client: Message and Fields are shown as
+// is, Code identifies the error and errors.Is matches it against its kind.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func NotFound(code string, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Forbidden(code string, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func Conflict(code string, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Unauthorized(code string, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func TooLarge(code string, message string) *Error {
+	return &Error{Kind: ErrTooLarge, Code: code, Message: message}
+}
+
+func Unsupported(code string, message string) *Error {
+	return &Error{Kind: ErrUnsupported, Code: code, Message: message}
+}
+
+// Validation returns a validation error, optionally listing the fields at
+// fault.
+func Validation(code string, message string, fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+// Field returns a validation error about a single field of the request.
+func Field(field string, message string) *Error {
+	return Validation(CODE_VALIDATION, message, FieldError{Field: field, Message: message})
+}
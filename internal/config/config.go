@@ -0,0 +1,422 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DEFAULT_FILE is read when no config file is named, if it exists.
+const DEFAULT_FILE = ".env"
+
+// Config is every setting of the server. Each one is read, from highest to
+// lowest precedence, from the command line flag, the environment variable,
+// the config file and finally the default, as named by the env and default
+// tags. Flags are the env names in lowercase with dashes, e.g. -server-addr
+// for SERVER_ADDR.
+type Config struct {
+	Server   Server
+	Database Database
+	JWT      JWT
+	Storage  Storage
+	Workers  Workers
+	Features Features
+	Log      Log
+	Tracing  Tracing
+}
+
+type Server struct {
+	Addr              string        `env:"SERVER_ADDR" default:":3000"`
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" default:"10s"`
+	ReadTimeout       time.Duration `env:"SERVER_READ_TIMEOUT" default:"60s"`
+	WriteTimeout      time.Duration `env:"SERVER_WRITE_TIMEOUT" default:"60s"`
+	IdleTimeout       time.Duration `env:"SERVER_IDLE_TIMEOUT" default:"120s"`
+	ShutdownTimeout   time.Duration `env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s"`
+	MaxUploadBytes    int64         `env:"UPLOAD_MAX_BYTES" default:"10485760"`
+}
+
+// Database names the PostgreSQL primary either with DSN or with the
+// separate fields, which are ignored when DSN is set. ReplicaDSNs lists
+// read replicas, which share the pool settings.
+type Database struct {
+	DSN                 string        `env:"DB_DSN"`
+	Host                string        `env:"DB_HOST" default:"localhost"`
+	Port                int           `env:"DB_PORT" default:"5432"`
+	User                string        `env:"DB_USER"`
+	Password            string        `env:"DB_PASSWORD"`
+	Name                string        `env:"DB_NAME"`
+	SSLMode             string        `env:"DB_SSLMODE" default:"prefer"`
+	ReplicaDSNs         []string      `env:"DB_REPLICA_DSNS"`
+	MaxOpenConns        int           `env:"DB_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns        int           `env:"DB_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime     time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime     time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+	ConnectTimeout      time.Duration `env:"DB_CONNECT_TIMEOUT" default:"1m"`
+	ConnectBackoff      time.Duration `env:"DB_CONNECT_BACKOFF" default:"500ms"`
+	HealthCheckInterval time.Duration `env:"DB_HEALTH_CHECK_INTERVAL" default:"15s"`
+}
+
+// JWT configures the signing keys, see helper.LoadKeySet, and the claims
+// every token must carry.
+type JWT struct {
+	Keys      string        `env:"JWT_KEYS"`
+	Secret    string        `env:"JWT_SECRET"`
+	ActiveKID string        `env:"JWT_ACTIVE_KID"`
+	Issuer    string        `env:"JWT_ISSUER" default:"mygram"`
+	Audience  string        `env:"JWT_AUDIENCE" default:"mygram-api"`
+	Leeway    time.Duration `env:"JWT_LEEWAY" default:"30s"`
+}
+
+type Storage struct {
+	Driver            string `env:"STORAGE_DRIVER" default:"local"`
+	LocalRoot         string `env:"STORAGE_LOCAL_ROOT" default:"./uploads"`
+	S3Endpoint        string `env:"S3_ENDPOINT"`
+	S3Region          string `env:"S3_REGION"`
+	S3Bucket          string `env:"S3_BUCKET"`
+	S3AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+}
+
+type Workers struct {
+	ImageWorkers   int `env:"IMAGE_WORKERS" default:"2"`
+	ImageQueueSize int `env:"IMAGE_QUEUE_SIZE" default:"256"`
+	EventWorkers   int `env:"EVENT_WORKERS" default:"1"`
+	EventQueueSize int `env:"EVENT_QUEUE_SIZE" default:"1024"`
+}
+
+type Features struct {
+	Search      bool `env:"FEATURE_SEARCH" default:"true"`
+	Stream      bool `env:"FEATURE_STREAM" default:"true"`
+	AutoMigrate bool `env:"FEATURE_AUTO_MIGRATE" default:"false"`
+	Metrics     bool `env:"FEATURE_METRICS" default:"true"`
+}
+
+type Log struct {
+	Level  string `env:"LOG_LEVEL" default:"info"`
+	Format string `env:"LOG_FORMAT" default:"json"`
+}
+
+// Tracing picks where the spans go: nowhere, to stdout, or over OTLP/HTTP
+// to the collector named by the standard OTEL_EXPORTER_OTLP_* variables.
+// SampleRatio is the share of new traces recorded; requests carrying a
+// trace context follow the caller's decision.
+type Tracing struct {
+	Exporter    string  `env:"TRACING_EXPORTER" default:"none"`
+	ServiceName string  `env:"OTEL_SERVICE_NAME" default:"mygram"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+var (
+	sslModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "text"}
+	exporters  = []string{"none", "stdout", "otlp"}
+)
+
+// setting is a leaf field of Config along with its env name.
+type setting struct {
+	key   string
+	def   string
+	field reflect.Value
+}
+
+// flagValue records a flag given on the command line. Boolean settings may
+// be given without a value, like -feature-search.
+type flagValue struct {
+	isBool bool
+	value  *string
+}
+
+func (f flagValue) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f flagValue) Set(v string) error {
+	*f.value = v
+	return nil
+}
+
+func (f flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// Load reads the configuration from args, the environment and the config
+// file named by -config or CONFIG_FILE (DEFAULT_FILE if it exists
+// otherwise). It returns the arguments left after the flags, such as a
+// subcommand. Only the syntax of the values is checked: callers validate
+// the sections they use.
+func Load(args []string) (Config, []string, error) {
+	cfg := Config{}
+	settings := collect(reflect.ValueOf(&cfg).Elem())
+
+	fs := flag.NewFlagSet("mygram", flag.ContinueOnError)
+	file := fs.String("config", "", "config file of KEY=VALUE lines (env CONFIG_FILE)")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		value := new(string)
+		flagValues[s.key] = value
+		usage := s.key
+		if s.def != "" {
+			usage += " (default " + s.def + ")"
+		}
+		fs.Var(flagValue{isBool: s.field.Kind() == reflect.Bool, value: value}, flagName(s.key), usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = DEFAULT_FILE, false
+	}
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return Config{}, nil, fmt.Errorf("reading config file: %w", err)
+		}
+		fileValues = map[string]string{}
+	}
+
+	errs := []error{}
+	for _, s := range settings {
+		value := s.def
+		if v, ok := fileValues[s.key]; ok {
+			value = v
+		}
+		if v, ok := os.LookupEnv(s.key); ok {
+			value = v
+		}
+		if given[flagName(s.key)] {
+			value = *flagValues[s.key]
+		}
+		if err := set(s.field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// Validate checks that the required settings are given and the others are
+// within range, reporting every problem at once.
+func (c Config) Validate() error {
+	return errors.Join(
+		c.Server.Validate(),
+		c.Database.Validate(),
+		c.JWT.Validate(),
+		c.Storage.Validate(),
+		c.Workers.Validate(),
+		c.Log.Validate(),
+		c.Tracing.Validate(),
+	)
+}
+
+func (s Server) Validate() error {
+	p := problems{}
+	_, _, err := net.SplitHostPort(s.Addr)
+	p.check(err == nil, "SERVER_ADDR: %q is not a host:port address", s.Addr)
+	p.check(s.ReadHeaderTimeout >= 0, "SERVER_READ_HEADER_TIMEOUT: must not be negative")
+	p.check(s.ReadTimeout >= 0, "SERVER_READ_TIMEOUT: must not be negative")
+	p.check(s.WriteTimeout >= 0, "SERVER_WRITE_TIMEOUT: must not be negative")
+	p.check(s.IdleTimeout >= 0, "SERVER_IDLE_TIMEOUT: must not be negative")
+	p.check(s.ShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT: must be positive")
+	p.check(s.MaxUploadBytes > 0, "UPLOAD_MAX_BYTES: must be positive")
+	return p.err()
+}
+
+func (d Database) Validate() error {
+	p := problems{}
+	if d.DSN == "" {
+		p.check(d.Host != "", "DB_HOST: is required unless DB_DSN is set")
+		p.check(d.Port > 0 && d.Port < 65536, "DB_PORT: %d is not a valid port", d.Port)
+		p.check(d.User != "", "DB_USER: is required unless DB_DSN is set")
+		p.check(d.Name != "", "DB_NAME: is required unless DB_DSN is set")
+		p.check(slices.Contains(sslModes, d.SSLMode), "DB_SSLMODE: must be one of %s", strings.Join(sslModes, ", "))
+	}
+	p.check(d.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS: must not be negative")
+	p.check(d.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS: must not be negative")
+	p.check(d.MaxOpenConns == 0 || d.MaxIdleConns <= d.MaxOpenConns, "DB_MAX_IDLE_CONNS: must not exceed DB_MAX_OPEN_CONNS")
+	p.check(d.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME: must not be negative")
+	p.check(d.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME: must not be negative")
+	p.check(d.ConnectTimeout >= 0, "DB_CONNECT_TIMEOUT: must not be negative")
+	p.check(d.ConnectBackoff > 0, "DB_CONNECT_BACKOFF: must be positive")
+	p.check(d.HealthCheckInterval >= 0, "DB_HEALTH_CHECK_INTERVAL: must not be negative")
+	return p.err()
+}
+
+func (j JWT) Validate() error {
+	p := problems{}
+	p.check(j.Keys != "" || j.Secret != "", "JWT_KEYS or JWT_SECRET: one of them is required")
+	// the length helper.LoadKeySet requires of HS512 secrets
+	p.check(j.Keys != "" || j.Secret == "" || len(j.Secret) >= 32, "JWT_SECRET: must be at least 32 bytes")
+	p.check(j.Issuer != "", "JWT_ISSUER: must not be empty")
+	p.check(j.Audience != "", "JWT_AUDIENCE: must not be empty")
+	p.check(j.Leeway >= 0, "JWT_LEEWAY: must not be negative")
+	return p.err()
+}
+
+func (s Storage) Validate() error {
+	p := problems{}
+	switch s.Driver {
+	case "local":
+		p.check(s.LocalRoot != "", "STORAGE_LOCAL_ROOT: is required by the local driver")
+	case "s3":
+		p.check(s.S3Endpoint != "", "S3_ENDPOINT: is required by the s3 driver")
+		p.check(s.S3Bucket != "", "S3_BUCKET: is required by the s3 driver")
+	default:
+		p.check(false, "STORAGE_DRIVER: must be local or s3, not %q", s.Driver)
+	}
+	return p.err()
+}
+
+func (w Workers) Validate() error {
+	p := problems{}
+	p.check(w.ImageWorkers > 0, "IMAGE_WORKERS: must be positive")
+	p.check(w.ImageQueueSize > 0, "IMAGE_QUEUE_SIZE: must be positive")
+	p.check(w.EventWorkers > 0, "EVENT_WORKERS: must be positive")
+	p.check(w.EventQueueSize > 0, "EVENT_QUEUE_SIZE: must be positive")
+	return p.err()
+}
+
+func (l Log) Validate() error {
+	p := problems{}
+	p.check(slices.Contains(logLevels, l.Level), "LOG_LEVEL: must be one of %s", strings.Join(logLevels, ", "))
+	p.check(slices.Contains(logFormats, l.Format), "LOG_FORMAT: must be one of %s", strings.Join(logFormats, ", "))
+	return p.err()
+}
+
+func (t Tracing) Validate() error {
+	p := problems{}
+	p.check(slices.Contains(exporters, t.Exporter), "TRACING_EXPORTER: must be one of %s", strings.Join(exporters, ", "))
+	p.check(t.ServiceName != "", "OTEL_SERVICE_NAME: is required")
+	p.check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	return p.err()
+}
+
+// problems collects the invalid settings of a section.
+type problems []error
+
+func (p *problems) check(ok bool, format string, args ...any) {
+	if !ok {
+		*p = append(*p, fmt.Errorf(format, args...))
+	}
+}
+
+func (p problems) err() error {
+	return errors.Join(p...)
+}
+
+// ConnectionString is DSN, or the key/value connection string built from
+// the separate fields.
+func (d Database) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), d.SSLMode)
+}
+
+// quote escapes a connection string value, which may hold spaces or quotes.
+func quote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func collect(v reflect.Value) []setting {
+	settings := []setting{}
+	for i := 0; i < v.NumField(); i++ {
+		field, tag := v.Field(i), v.Type().Field(i).Tag
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Duration(0)) {
+			settings = append(settings, collect(field)...)
+			continue
+		}
+		if key := tag.Get("env"); key != "" {
+			settings = append(settings, setting{key: key, def: tag.Get("default"), field: field})
+		}
+	}
+	return settings
+}
+
+func set(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s", value)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Type() == reflect.TypeOf([]string{}):
+		// comma separated, ignoring blank entries
+		list := []string{}
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(n)
+	case field.Kind() == reflect.Float64:
+		if value == "" {
+			field.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Bool:
+		if value == "" {
+			field.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTValidateSecretLength(t *testing.T) {
+	tests := []struct {
+		jwt     JWT
+		invalid bool
+	}{
+		{JWT{}, true},
+		{JWT{Secret: "secret"}, true},
+		{JWT{Secret: strings.Repeat("s", 32)}, false},
+		{JWT{Keys: "k1:RS256:/keys/k1.pem", Secret: "secret"}, false},
+	}
+	for _, tt := range tests {
+		tt.jwt.Issuer, tt.jwt.Audience = "mygram", "mygram-api"
+		err := tt.jwt.Validate()
+		if (err != nil) != tt.invalid {
+			t.Errorf("Validate(%+v) = %v, want invalid %v", tt.jwt, err, tt.invalid)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg Config, rest []string)
+		errs  []string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if cfg.Server.Addr != ":3000" || cfg.JWT.Leeway != 30*time.Second || !cfg.Features.Search || cfg.Features.AutoMigrate {
+					t.Errorf("got %+v %+v %+v", cfg.Server, cfg.JWT, cfg.Features)
+				}
+				if cfg.Tracing.SampleRatio != 1 || cfg.Server.MaxUploadBytes != 10485760 || len(cfg.Database.ReplicaDSNs) != 0 {
+					t.Errorf("got %+v %+v %+v", cfg.Tracing, cfg.Server, cfg.Database)
+				}
+			},
+		},
+		{
+			name: "file over default",
+			file: "SERVER_ADDR=:4000\nLOG_LEVEL=debug\n",
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if cfg.Server.Addr != ":4000" || cfg.Log.Level != "debug" {
+					t.Errorf("got addr %q, level %q", cfg.Server.Addr, cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			file: "SERVER_ADDR=:4000\nLOG_LEVEL=debug\n",
+			env:  map[string]string{"SERVER_ADDR": ":5000"},
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if cfg.Server.Addr != ":5000" || cfg.Log.Level != "debug" {
+					t.Errorf("got addr %q, level %q", cfg.Server.Addr, cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name: "flag over env",
+			file: "SERVER_ADDR=:4000\n",
+			env:  map[string]string{"SERVER_ADDR": ":5000", "LOG_LEVEL": "warn"},
+			args: []string{"-server-addr", ":6000", "migrate", "up"},
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if cfg.Server.Addr != ":6000" || cfg.Log.Level != "warn" {
+					t.Errorf("got addr %q, level %q", cfg.Server.Addr, cfg.Log.Level)
+				}
+				if strings.Join(rest, " ") != "migrate up" {
+					t.Errorf("got args %q, want the subcommand", rest)
+				}
+			},
+		},
+		{
+			name: "empty env still overrides",
+			file: "DB_USER=mygram\n",
+			env:  map[string]string{"DB_USER": ""},
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if cfg.Database.User != "" {
+					t.Errorf("got user %q, want it cleared", cfg.Database.User)
+				}
+			},
+		},
+		{
+			name: "bool flags",
+			env:  map[string]string{"FEATURE_SEARCH": "false", "FEATURE_METRICS": "false"},
+			args: []string{"-feature-auto-migrate", "-feature-search", "-feature-stream=false"},
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if !cfg.Features.AutoMigrate || !cfg.Features.Search || cfg.Features.Stream || cfg.Features.Metrics {
+					t.Errorf("got %+v", cfg.Features)
+				}
+			},
+		},
+		{
+			name: "durations and lists",
+			file: "DB_REPLICA_DSNS=postgres://a, ,postgres://b,\nDB_CONNECT_BACKOFF=250ms\n",
+			env:  map[string]string{"SERVER_READ_TIMEOUT": " 1m30s ", "JWT_LEEWAY": ""},
+			args: []string{"-tracing-sample-ratio=0.25"},
+			check: func(t *testing.T, cfg Config, rest []string) {
+				if strings.Join(cfg.Database.ReplicaDSNs, " ") != "postgres://a postgres://b" {
+					t.Errorf("got replicas %q", cfg.Database.ReplicaDSNs)
+				}
+				if cfg.Database.ConnectBackoff != 250*time.Millisecond || cfg.Server.ReadTimeout != 90*time.Second || cfg.JWT.Leeway != 0 {
+					t.Errorf("got backoff %v, read timeout %v, leeway %v", cfg.Database.ConnectBackoff, cfg.Server.ReadTimeout, cfg.JWT.Leeway)
+				}
+				if cfg.Tracing.SampleRatio != 0.25 {
+					t.Errorf("got sample ratio %v", cfg.Tracing.SampleRatio)
+				}
+			},
+		},
+		{
+			name: "invalid values are all reported",
+			file: "DB_PORT=postgres\n",
+			env:  map[string]string{"SERVER_IDLE_TIMEOUT": "2 minutes"},
+			args: []string{"-feature-search=maybe"},
+			errs: []string{"DB_PORT", "SERVER_IDLE_TIMEOUT", "FEATURE_SEARCH"},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-server-address", ":6000"},
+			errs: []string{"server-address"},
+		},
+		{
+			name: "missing config file",
+			args: []string{"-config", "does-not-exist.env"},
+			errs: []string{"reading config file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mygram.env")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CONFIG_FILE", path)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, rest, err := Load(tt.args)
+			if len(tt.errs) > 0 {
+				if err == nil {
+					t.Fatalf("got no error, want one about %s", strings.Join(tt.errs, ", "))
+				}
+				for _, want := range tt.errs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("got error %v, want one about %s", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg, rest)
+		})
+	}
+}
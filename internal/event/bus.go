@@ -0,0 +1,143 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/geedotrar/mygram/pkg/logger"
+)
+
+const (
+	TYPE_COMMENT_CREATED = "comment.created"
+	TYPE_REPLY_CREATED   = "reply.created"
+	TYPE_LIKE_CREATED    = "like.created"
+	TYPE_FOLLOW_CREATED  = "follow.created"
+	TYPE_MENTION_CREATED = "mention.created"
+
+	// TYPE_PHOTO_COMMENTED is published once for every new comment, for
+	// everyone watching the photo rather than a single recipient.
+	TYPE_PHOTO_COMMENTED = "photo.commented"
+	// TYPE_NOTIFICATION_CREATED is published once a notification is stored,
+	// with the notification as the target.
+	TYPE_NOTIFICATION_CREATED = "notification.created"
+)
+
+// Event tells RecipientID that ActorID did something to a photo, comment or
+// to them. TargetType is empty for follows, and RecipientID is zero for
+// events meant for no one in particular. PhotoID is the photo the target
+// belongs to, so consumers can link to it without another lookup.
+type Event struct {
+	Type        string
+	ActorID     uint64
+	RecipientID uint64
+	TargetType  string
+	TargetID    uint64
+	PhotoID     uint64
+	CreatedAt   time.Time
+}
+
+type Handler func(ctx context.Context, e Event) error
+
+// Bus delivers events to its subscribers on background goroutines, so the
+// request that published an event never waits for, or fails because of, the
+// consumers.
+type Bus struct {
+	events   chan Event
+	handlers []Handler
+	workers  int
+	wg       sync.WaitGroup
+	running  atomic.Bool
+	cancel   context.CancelFunc
+}
+
+func NewBus(workers int, queueSize int) *Bus {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Bus{
+		events:  make(chan Event, queueSize),
+		workers: workers,
+	}
+}
+
+// Subscribe registers a handler for every published event. It must be
+// called before Start.
+func (b *Bus) Subscribe(handler Handler) {
+	b.handlers = append(b.handlers, handler)
+}
+
+// Start launches the workers delivering queued events to the subscribers.
+func (b *Bus) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.running.Store(true)
+	for i := 0; i < b.workers; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for e := range b.events {
+				ctx := logger.With(ctx, "event", e.Type, "actor_id", e.ActorID)
+				for _, handler := range b.handlers {
+					if err := handler(ctx, e); err != nil {
+						logger.FromContext(ctx).Error("cannot handle event", "error", err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Publish queues an event without blocking. It reports false, dropping the
+// event, when the queue is full or the bus is stopped.
+func (b *Bus) Publish(e Event) (ok bool) {
+	if !b.running.Load() {
+		return false
+	}
+	defer func() {
+		// the queue was closed by a concurrent Stop
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	select {
+	case b.events <- e:
+		return true
+	default:
+		slog.Warn("event queue is full, dropping event", "event", e.Type)
+		return false
+	}
+}
+
+// Running reports whether the workers have been started and not stopped.
+func (b *Bus) Running() bool {
+	return b.running.Load()
+}
+
+// Stop delivers the queued events. If ctx ends first the deliveries in
+// flight are cancelled and Stop returns ctx.Err().
+func (b *Bus) Stop(ctx context.Context) error {
+	if !b.running.CompareAndSwap(true, false) {
+		return nil
+	}
+	close(b.events)
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
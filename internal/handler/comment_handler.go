@@ -4,20 +4,24 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/middleware"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/service"
-	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/geedotrar/mygram/pkg/pagination"
 	"github.com/gin-gonic/gin"
 )
 
 type CommentHandler interface {
 	GetCommentsByPhotoID(ctx *gin.Context)
+	GetCommentReplies(ctx *gin.Context)
 	CreateComment(ctx *gin.Context)
 	UpdateComment(ctx *gin.Context)
 	DeleteComment(ctx *gin.Context)
 	GetCommentByID(ctx *gin.Context)
 	GetComments(ctx *gin.Context)
+
+	CommentOwner(ctx *gin.Context) (uint64, error)
 }
 
 type commentHandlerImpl struct {
@@ -32,86 +36,104 @@ func (s *commentHandlerImpl) GetCommentsByPhotoID(ctx *gin.Context) {
 	photoIDStr := ctx.Query("photo_id")
 
 	if photoIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Photo ID is required"})
+		ctx.Error(apperror.Field("photo_id", "photo ID is required"))
 		return
 	}
 
 	photoID, err := strconv.ParseUint(photoIDStr, 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid photo ID"})
+		ctx.Error(apperror.Field("photo_id", "invalid photo ID"))
 		return
 	}
 
-	comments, err := s.commentService.GetCommentsByPhotoID(ctx, photoID)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if len(comments) == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Comment not found"})
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	comments, err := s.commentService.GetCommentsByPhotoID(ctx, photoID, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, comments)
 }
 
-func (c *commentHandlerImpl) CreateComment(ctx *gin.Context) {
-	comment := model.CreateComment{}
-	if err := ctx.ShouldBindJSON(&comment); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+func (c *commentHandlerImpl) GetCommentReplies(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	createdComment, err := c.commentService.CreateComment(ctx, comment, uint64(ctx.MustGet(middleware.CLAIM_USER_ID).(float64)))
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	replies, err := c.commentService.GetCommentReplies(ctx, id, claim.UserID, params)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, createdComment)
+	ctx.JSON(http.StatusOK, replies)
 }
 
-func (c *commentHandlerImpl) UpdateComment(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+func (c *commentHandlerImpl) CreateComment(ctx *gin.Context) {
+	comment := model.CreateComment{}
+	if err := ctx.ShouldBindJSON(&comment); err != nil {
+		ctx.Error(bindError(err))
 		return
 	}
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
+
+	claim, ok := middleware.GetAccessClaim(ctx)
 	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user session"})
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-	comment, err := c.commentService.GetCommentByID1(ctx, uint64(id))
+
+	createdComment, err := c.commentService.CreateComment(ctx, comment, claim.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if comment.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Photo not found"})
-		return
-	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid user id session"})
+	ctx.JSON(http.StatusCreated, createdComment)
+}
+
+func (c *commentHandlerImpl) UpdateComment(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
-
-	if int(comment.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to edit this photo"})
+	comment, err := c.commentService.GetCommentByID1(ctx, uint64(id))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	if err := ctx.ShouldBindJSON(&comment); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+		ctx.Error(bindError(err))
 		return
 	}
 
 	updatedComment, err := c.commentService.UpdateComment(ctx, uint64(id), comment)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -121,34 +143,13 @@ func (c *commentHandlerImpl) UpdateComment(ctx *gin.Context) {
 func (c *commentHandlerImpl) DeleteComment(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
-		return
-	}
-
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "Invalid user session"})
-		return
-	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid user ID session"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
 	comment, err := c.commentService.DeleteCommentByID(ctx, uint64(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
-		return
-	}
-
-	if comment.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "comment not found"})
-		return
-	}
-
-	if int(comment.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to delete this comment"})
+		ctx.Error(err)
 		return
 	}
 
@@ -160,31 +161,51 @@ func (c *commentHandlerImpl) DeleteComment(ctx *gin.Context) {
 func (c *commentHandlerImpl) GetCommentByID(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid photo ID"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
-	comment, err := c.commentService.GetCommentByID(ctx, id)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-	if comment.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "comment not found"})
+	comment, err := c.commentService.GetCommentByID(ctx, id, claim.UserID)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-
 	ctx.JSON(http.StatusOK, comment)
 }
 func (c *commentHandlerImpl) GetComments(ctx *gin.Context) {
-	comments, err := c.commentService.GetComments(ctx)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
+		return
+	}
+
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-	if len(comments) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"message": "No comment found"})
+	comments, err := c.commentService.GetComments(ctx, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, comments)
 }
+
+// CommentOwner is the middleware.OwnerFunc for /comments/:id routes.
+func (c *commentHandlerImpl) CommentOwner(ctx *gin.Context) (uint64, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		return 0, middleware.ErrInvalidParam
+	}
+	comment, err := c.commentService.GetCommentByID1(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return comment.UserID, nil
+}
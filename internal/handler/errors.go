@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errInvalidBody = apperror.Validation("invalid_body", "invalid request body")
+	errTooLarge    = apperror.TooLarge("photo_too_large", "photo is too large")
+)
+
+func init() {
+	// name the fields in validation errors the way clients send them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(requestName)
+	}
+}
+
+// bindError turns an error binding the request body into a validation
+// error, listing the fields that failed their binding tags.
+func bindError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errTooLarge
+	}
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return errors.Join(errInvalidBody, err)
+	}
+	fields := make([]apperror.FieldError, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		message := fieldErr.Field() + " is invalid"
+		if fieldErr.Tag() == "required" {
+			message = fieldErr.Field() + " is required"
+		}
+		fields = append(fields, apperror.FieldError{Field: fieldErr.Field(), Message: message})
+	}
+	return apperror.Validation(apperror.CODE_VALIDATION, errInvalidBody.Message, fields...)
+}
+
+// requestName returns the JSON name of a struct field, falling back to its
+// form name and then to its Go name.
+func requestName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		name = strings.TrimSpace(name)
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/version"
+	"github.com/geedotrar/mygram/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds all the readiness checks together, so a hung
+// dependency makes the probe fail rather than hang.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck reports whether a dependency the server needs to take
+// traffic is available.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler interface {
+	Healthz(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
+	Version(ctx *gin.Context)
+}
+
+type healthHandlerImpl struct {
+	checks  []ReadinessCheck
+	version version.Info
+}
+
+func NewHealthHandler(checks ...ReadinessCheck) HealthHandler {
+	return &healthHandlerImpl{checks: checks, version: version.Get()}
+}
+
+// Healthz only tells the process is serving requests.
+func (h *healthHandlerImpl) Healthz(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, map[string]any{
+		"status": "ok",
+	})
+}
+
+// Readyz runs every readiness check, answering 503 with the names of the
+// failed ones when any of them fails. The probe is public, so the errors
+// only go to the log.
+func (h *healthHandlerImpl) Readyz(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	status, code := "ok", http.StatusOK
+	results := map[string]string{}
+	for _, check := range h.checks {
+		if err := check.Check(checkCtx); err != nil {
+			logger.FromContext(ctx.Request.Context()).Error("readiness check failed", "check", check.Name, "error", err)
+			results[check.Name] = "unavailable"
+			status, code = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(code, map[string]any{
+		"status": status,
+		"checks": results,
+	})
+}
+
+func (h *healthHandlerImpl) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.version)
+}
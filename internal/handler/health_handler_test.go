@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geedotrar/mygram/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyz(t *testing.T) {
+	const detail = "dial tcp 10.0.3.7:5432: connection refused"
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New(detail) }
+
+	tests := []struct {
+		name   string
+		checks []ReadinessCheck
+		status int
+		want   map[string]any
+	}{
+		{
+			name:   "all ok",
+			checks: []ReadinessCheck{{"postgres", ok}, {"storage", ok}},
+			status: http.StatusOK,
+			want:   map[string]any{"status": "ok", "checks": map[string]any{"postgres": "ok", "storage": "ok"}},
+		},
+		{
+			name:   "one failing",
+			checks: []ReadinessCheck{{"postgres", failing}, {"storage", ok}},
+			status: http.StatusServiceUnavailable,
+			want:   map[string]any{"status": "unavailable", "checks": map[string]any{"postgres": "unavailable", "storage": "ok"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			var logs bytes.Buffer
+			g := gin.New()
+			g.GET("/readyz", func(ctx *gin.Context) {
+				ctx.Request = ctx.Request.WithContext(logger.NewContext(ctx.Request.Context(), logger.New(&logs, logger.FORMAT_JSON, "info")))
+			}, NewHealthHandler(tt.checks...).Readyz)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("got status %d, want %d", w.Code, tt.status)
+			}
+			if strings.Contains(w.Body.String(), detail) {
+				t.Errorf("error details leaked: %s", w.Body.String())
+			}
+			want, _ := json.Marshal(tt.want)
+			if got := strings.TrimSpace(w.Body.String()); got != string(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+			if logged := strings.Contains(logs.String(), detail); logged != (tt.status != http.StatusOK) {
+				t.Errorf("error logged: %v, logs: %s", logged, logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/geedotrar/mygram/pkg/helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler interface {
+	GetJWKS(ctx *gin.Context)
+}
+
+type keyHandlerImpl struct {
+	keys *helper.KeySet
+}
+
+func NewKeyHandler(keys *helper.KeySet) KeyHandler {
+	return &keyHandlerImpl{keys: keys}
+}
+
+func (k *keyHandlerImpl) GetJWKS(ctx *gin.Context) {
+	// verifiers may cache, but not past a rotation window
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, k.keys.JWKS())
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/service"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LikeHandler interface {
+	LikePhoto(ctx *gin.Context)
+	UnlikePhoto(ctx *gin.Context)
+	GetPhotoLikes(ctx *gin.Context)
+	LikeComment(ctx *gin.Context)
+	UnlikeComment(ctx *gin.Context)
+}
+
+type likeHandlerImpl struct {
+	likeService service.LikeService
+}
+
+func NewLikeHandler(likeService service.LikeService) LikeHandler {
+	return &likeHandlerImpl{likeService: likeService}
+}
+
+func (l *likeHandlerImpl) LikePhoto(ctx *gin.Context) {
+	l.setLike(ctx, model.TargetPhoto, true)
+}
+
+func (l *likeHandlerImpl) UnlikePhoto(ctx *gin.Context) {
+	l.setLike(ctx, model.TargetPhoto, false)
+}
+
+func (l *likeHandlerImpl) LikeComment(ctx *gin.Context) {
+	l.setLike(ctx, model.TargetComment, true)
+}
+
+func (l *likeHandlerImpl) UnlikeComment(ctx *gin.Context) {
+	l.setLike(ctx, model.TargetComment, false)
+}
+
+func (l *likeHandlerImpl) GetPhotoLikes(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	users, err := l.likeService.GetLikers(ctx, model.TargetPhoto, id, params)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, users)
+}
+
+// setLike likes or unlikes the target in the :id param. Both are
+// idempotent, so repeating either one still succeeds.
+func (l *likeHandlerImpl) setLike(ctx *gin.Context, targetType string, like bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+
+	if like {
+		err = l.likeService.Like(ctx, claim.UserID, targetType, id)
+	} else {
+		err = l.likeService.Unlike(ctx, claim.UserID, targetType, id)
+	}
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]any{
+		"liked": like,
+	})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/service"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler interface {
+	GetNotifications(ctx *gin.Context)
+	MarkNotificationsRead(ctx *gin.Context)
+}
+
+type notificationHandlerImpl struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) NotificationHandler {
+	return &notificationHandlerImpl{notificationService: notificationService}
+}
+
+func (n *notificationHandlerImpl) GetNotifications(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	notifications, err := n.notificationService.GetNotifications(ctx, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+// MarkNotificationsRead marks the notifications listed in ids as read, or
+// every unread notification when the body or the list is empty.
+func (n *notificationHandlerImpl) MarkNotificationsRead(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	read := model.ReadNotifications{}
+	if err := ctx.ShouldBindJSON(&read); err != nil && !errors.Is(err, io.EOF) {
+		ctx.Error(bindError(err))
+		return
+	}
+
+	if err := n.notificationService.MarkRead(ctx, claim.UserID, read.IDs); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]any{
+		"message": "notifications marked as read",
+	})
+}
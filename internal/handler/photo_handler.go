@@ -1,13 +1,15 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/middleware"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/service"
-	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,84 +17,121 @@ import (
 type PhotoHandler interface {
 	GetPhotoByUserID(ctx *gin.Context)
 	GetPhotos(ctx *gin.Context)
+	GetFeed(ctx *gin.Context)
+	GetPhotosByTag(ctx *gin.Context)
 	GetPhotoByID(ctx *gin.Context)
 	DeletePhotoByID(ctx *gin.Context)
 	CreatePhoto(ctx *gin.Context)
 	UpdatePhoto(ctx *gin.Context)
+	GetPhotoImage(ctx *gin.Context)
+
+	PhotoOwner(ctx *gin.Context) (uint64, error)
 }
 
 type photoHandlerImpl struct {
-	photoService service.PhotoService
+	photoService  service.PhotoService
+	maxUploadSize int64
 }
 
-func NewPhotoHandler(photoService service.PhotoService) PhotoHandler {
-	return &photoHandlerImpl{photoService: photoService}
+func NewPhotoHandler(photoService service.PhotoService, maxUploadSize int64) PhotoHandler {
+	return &photoHandlerImpl{photoService: photoService, maxUploadSize: maxUploadSize}
 }
 
 func (p *photoHandlerImpl) GetPhotos(ctx *gin.Context) {
-	photos, err := p.photoService.GetPhotos(ctx)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
-	if len(photos) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"message": "No photo found"})
+
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	photos, err := p.photoService.GetPhotos(ctx, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, photos)
 }
 
-func (p *photoHandlerImpl) GetPhotoByID(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid photo ID"})
+func (p *photoHandlerImpl) GetFeed(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-
-	photo, err := p.photoService.GetPhotoByID(ctx, id)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
-	if photo.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "photo not found"})
+
+	photos, err := p.photoService.GetFeed(ctx, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-
-	ctx.JSON(http.StatusOK, photo)
+	ctx.JSON(http.StatusOK, photos)
 }
 
-func (p *photoHandlerImpl) DeletePhotoByID(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+func (p *photoHandlerImpl) GetPhotosByTag(ctx *gin.Context) {
+	tag := ctx.Param("tag")
+	if tag == "" {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
+	claim, ok := middleware.GetAccessClaim(ctx)
 	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "Invalid user session"})
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid user ID session"})
+	photos, err := p.photoService.GetPhotosByTag(ctx, tag, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, photos)
+}
+
+func (p *photoHandlerImpl) GetPhotoByID(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
-	photo, err := p.photoService.DeletePhotoByID(ctx, uint64(id))
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	photo, err := p.photoService.GetPhotoByID(ctx, id, claim.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
+	ctx.JSON(http.StatusOK, photo)
+}
 
-	if photo.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Photo not found"})
+func (p *photoHandlerImpl) DeletePhotoByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
-	if int(photo.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to delete this photo"})
+	photo, err := p.photoService.DeletePhotoByID(ctx, uint64(id))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -103,94 +142,190 @@ func (p *photoHandlerImpl) DeletePhotoByID(ctx *gin.Context) {
 }
 
 func (p *photoHandlerImpl) CreatePhoto(ctx *gin.Context) {
+	if ctx.ContentType() == "multipart/form-data" {
+		p.uploadPhoto(ctx)
+		return
+	}
+
 	photo := model.CreatePhoto{}
-	if err := ctx.BindJSON(&photo); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+	if err := ctx.ShouldBindJSON(&photo); err != nil {
+		ctx.Error(bindError(err))
 		return
 	}
 
 	if err := photo.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
+		return
+	}
+
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
 
-	createdPhoto, err := p.photoService.CreatePhoto(ctx, photo, uint64(ctx.MustGet(middleware.CLAIM_USER_ID).(float64)))
+	createdPhoto, err := p.photoService.CreatePhoto(ctx, photo, claim.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, createdPhoto)
 }
-func (p *photoHandlerImpl) UpdatePhoto(ctx *gin.Context) {
 
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+// uploadPhoto handles a multipart POST /photos carrying the image in the
+// "photo" field next to the title and caption fields.
+func (p *photoHandlerImpl) uploadPhoto(ctx *gin.Context) {
+	// leave some room for the other form fields
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, p.maxUploadSize+1<<20)
+
+	photo := model.CreatePhoto{}
+	if err := ctx.ShouldBind(&photo); err != nil {
+		ctx.Error(bindError(err))
+		return
+	}
+	if err := photo.ValidateUpload(); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("photo")
+	if err != nil {
+		ctx.Error(apperror.Field("photo", "photo file is required"))
+		return
+	}
+	if fileHeader.Size > p.maxUploadSize {
+		ctx.Error(errTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
+	defer file.Close()
+
+	claim, ok := middleware.GetAccessClaim(ctx)
 	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user session"})
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
 
-	photo, err := p.photoService.GetPhotoByID(ctx, uint64(id))
+	createdPhoto, err := p.photoService.UploadPhoto(ctx, photo, file, claim.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if photo.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Photo not found"})
+	ctx.JSON(http.StatusCreated, createdPhoto)
+}
+
+// GetPhotoImage serves the stored image of a photo, or one of its resized
+// variants. Stored files never change, so clients and proxies may cache
+// them for good.
+func (p *photoHandlerImpl) GetPhotoImage(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid user id session"})
+
+	photo, object, err := p.photoService.GetPhotoImage(ctx, id, ctx.Param("variant"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	defer object.Body.Close()
+
+	etag := fmt.Sprintf("%q", photo.Checksum)
+	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Header("ETag", etag)
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.Status(http.StatusNotModified)
 		return
 	}
 
-	if int(photo.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to edit this photo"})
+	headers := map[string]string{}
+	if !object.ModTime.IsZero() {
+		headers["Last-Modified"] = object.ModTime.UTC().Format(http.TimeFormat)
+	}
+	ctx.DataFromReader(http.StatusOK, object.Size, photo.ContentType, object.Body, headers)
+}
+
+func (p *photoHandlerImpl) UpdatePhoto(ctx *gin.Context) {
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	photo, err := p.photoService.GetPhotoByID(ctx, uint64(id), claim.UserID)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	if err := ctx.ShouldBindJSON(&photo); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+		ctx.Error(bindError(err))
 		return
 	}
 
 	updatedPhoto, err := p.photoService.UpdatePhoto(ctx, uint64(id), photo)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, updatedPhoto)
 }
+
+// PhotoOwner is the middleware.OwnerFunc for /photos/:id routes.
+func (p *photoHandlerImpl) PhotoOwner(ctx *gin.Context) (uint64, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		return 0, middleware.ErrInvalidParam
+	}
+	claim, _ := middleware.GetAccessClaim(ctx)
+	photo, err := p.photoService.GetPhotoByID(ctx, id, claim.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return photo.UserID, nil
+}
 func (s *photoHandlerImpl) GetPhotoByUserID(ctx *gin.Context) {
 	userIDStr := ctx.Query("user_id")
 
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "User ID is required"})
+		ctx.Error(apperror.Field("user_id", "user ID is required"))
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid user ID"})
+		ctx.Error(apperror.Field("user_id", "invalid user ID"))
 		return
 	}
 
-	photos, err := s.photoService.GetPhotoByUserID(ctx, userID)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if len(photos) == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Photo not found"})
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	photos, err := s.photoService.GetPhotoByUserID(ctx, userID, claim.UserID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
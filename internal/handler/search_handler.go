@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/service"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler interface {
+	Search(ctx *gin.Context)
+}
+
+type searchHandlerImpl struct {
+	searchService service.SearchService
+}
+
+func NewSearchHandler(searchService service.SearchService) SearchHandler {
+	return &searchHandlerImpl{searchService: searchService}
+}
+
+// Search looks up q among the photos, users or comments, as chosen by
+// type, which defaults to photos. Results come best match first.
+func (s *searchHandlerImpl) Search(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	query := ctx.Query("q")
+	var results any
+	switch ctx.DefaultQuery("type", model.SearchPhotos) {
+	case model.SearchPhotos:
+		results, err = s.searchService.SearchPhotos(ctx, query, claim.UserID, params)
+	case model.SearchUsers:
+		results, err = s.searchService.SearchUsers(ctx, query, params)
+	case model.SearchComments:
+		results, err = s.searchService.SearchComments(ctx, query, claim.UserID, params)
+	default:
+		ctx.Error(apperror.Field("type", "type must be one of photos, users or comments"))
+		return
+	}
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, results)
+}
@@ -4,10 +4,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/middleware"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/service"
-	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +20,8 @@ type SocialMediaHandler interface {
 	CreateSocialMedia(ctx *gin.Context)
 	UpdateSocialMedia(ctx *gin.Context)
 	DeleteSocialMedia(ctx *gin.Context)
+
+	SocialMediaOwner(ctx *gin.Context) (uint64, error)
 }
 
 type socialMediaHandlerImpl struct {
@@ -33,24 +36,25 @@ func (s *socialMediaHandlerImpl) GetSocialMediasByUserID(ctx *gin.Context) {
 	userIDStr := ctx.Query("user_id")
 
 	if userIDStr == "" {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "User ID is required"})
+		ctx.Error(apperror.Field("user_id", "user ID is required"))
 		return
 	}
 
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid user ID"})
+		ctx.Error(apperror.Field("user_id", "invalid user ID"))
 		return
 	}
 
-	socialMedias, err := s.socialMediaService.GetSocialMediasByUserID(ctx, userID)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
-	if len(socialMedias) == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Social media not found"})
+	socialMedias, err := s.socialMediaService.GetSocialMediasByUserID(ctx, userID, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -60,13 +64,19 @@ func (s *socialMediaHandlerImpl) GetSocialMediasByUserID(ctx *gin.Context) {
 func (s *socialMediaHandlerImpl) CreateSocialMedia(ctx *gin.Context) {
 	socialMedia := model.CreateSocialMedia{}
 	if err := ctx.ShouldBindJSON(&socialMedia); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+		ctx.Error(bindError(err))
+		return
+	}
+
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
 
-	createdSocialMedia, err := s.socialMediaService.CreateSocialMedia(ctx, socialMedia, uint64(ctx.MustGet(middleware.CLAIM_USER_ID).(float64)))
+	createdSocialMedia, err := s.socialMediaService.CreateSocialMedia(ctx, socialMedia, claim.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -76,43 +86,23 @@ func (s *socialMediaHandlerImpl) CreateSocialMedia(ctx *gin.Context) {
 func (s *socialMediaHandlerImpl) UpdateSocialMedia(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
-		return
-	}
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user session"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 	socialMedia, err := s.socialMediaService.GetSocialMediaByID1(ctx, uint64(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
-		return
-	}
-
-	if socialMedia.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "Social media not found"})
-		return
-	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid user id session"})
-		return
-	}
-
-	if int(socialMedia.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to edit this social media"})
+		ctx.Error(err)
 		return
 	}
 
 	if err := ctx.ShouldBindJSON(&socialMedia); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+		ctx.Error(bindError(err))
 		return
 	}
 
 	updatedSocialMedia, err := s.socialMediaService.UpdateSocialMedia(ctx, uint64(id), socialMedia)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -122,34 +112,13 @@ func (s *socialMediaHandlerImpl) UpdateSocialMedia(ctx *gin.Context) {
 func (s *socialMediaHandlerImpl) DeleteSocialMedia(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
-		return
-	}
-
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "Invalid user session"})
-		return
-	}
-	userIdInt, ok := userId.(float64)
-	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid user ID session"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
 	socialMedia, err := s.socialMediaService.DeleteSocialMediaByID(ctx, uint64(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
-		return
-	}
-
-	if socialMedia.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "socialMedia not found"})
-		return
-	}
-
-	if int(socialMedia.UserID) != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "You are not authorized to delete this socialMedia"})
+		ctx.Error(err)
 		return
 	}
 
@@ -161,31 +130,41 @@ func (s *socialMediaHandlerImpl) DeleteSocialMedia(ctx *gin.Context) {
 func (s *socialMediaHandlerImpl) GetSocialMediaByID(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid social media ID"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
 	socialMedia, err := s.socialMediaService.GetSocialMediaByID(ctx, id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
-		return
-	}
-	if socialMedia.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "social media not found"})
+		ctx.Error(err)
 		return
 	}
-
 	ctx.JSON(http.StatusOK, socialMedia)
 }
 func (s *socialMediaHandlerImpl) GetSocialMedias(ctx *gin.Context) {
-	socialMedias, err := s.socialMediaService.GetSocialMedias(ctx)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
-	if len(socialMedias) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"message": "No social media found"})
+
+	socialMedias, err := s.socialMediaService.GetSocialMedias(ctx, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, socialMedias)
 }
+
+// SocialMediaOwner is the middleware.OwnerFunc for /socialmedias/:id routes.
+func (s *socialMediaHandlerImpl) SocialMediaOwner(ctx *gin.Context) (uint64, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if id == 0 || err != nil {
+		return 0, middleware.ErrInvalidParam
+	}
+	socialMedia, err := s.socialMediaService.GetSocialMediaByID1(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return socialMedia.UserID, nil
+}
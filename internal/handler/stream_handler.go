@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxStreamPhotos caps how many photos one stream may watch.
+	maxStreamPhotos = 50
+	// streamHeartbeat keeps idle streams from being cut by proxies.
+	streamHeartbeat = 30 * time.Second
+)
+
+type StreamHandler interface {
+	Stream(ctx *gin.Context)
+}
+
+type streamHandlerImpl struct {
+	streamService service.StreamService
+}
+
+func NewStreamHandler(streamService service.StreamService) StreamHandler {
+	return &streamHandlerImpl{streamService: streamService}
+}
+
+// Stream pushes the caller's new notifications, and the new comments on
+// the photos listed in photo_id (repeated or comma separated), as
+// server-sent events.
+func (s *streamHandlerImpl) Stream(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+	photoIDs := []uint64{}
+	for _, param := range ctx.QueryArray("photo_id") {
+		for _, value := range strings.Split(param, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+			if id == 0 || err != nil {
+				ctx.Error(apperror.Field("photo_id", "invalid photo_id"))
+				return
+			}
+			photoIDs = append(photoIDs, id)
+		}
+	}
+	if len(photoIDs) > maxStreamPhotos {
+		ctx.Error(apperror.Field("photo_id", "too many photo_id, max "+strconv.Itoa(maxStreamPhotos)))
+		return
+	}
+
+	sub := s.streamService.Subscribe(claim.UserID, photoIDs)
+	defer sub.Close()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// the stream outlives the server write timeout
+	if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case msg, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			ctx.SSEvent(msg.Type, msg.Data)
+			return true
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			return true
+		}
+	})
+}
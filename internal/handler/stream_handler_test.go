@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/stream"
+	"github.com/gin-gonic/gin"
+)
+
+// hubStreamService subscribes straight to a hub and hands every
+// subscription it makes to subs.
+type hubStreamService struct {
+	hub  *stream.Hub
+	subs chan *stream.Subscription
+}
+
+func (s *hubStreamService) Subscribe(userID uint64, photoIDs []uint64) *stream.Subscription {
+	topics := []string{stream.UserTopic(userID)}
+	for _, photoID := range photoIDs {
+		topics = append(topics, stream.PhotoTopic(photoID))
+	}
+	sub := s.hub.Subscribe(topics...)
+	s.subs <- sub
+	return sub
+}
+
+func (s *hubStreamService) HandleEvent(ctx context.Context, e event.Event) error {
+	return nil
+}
+
+func TestStreamUnsubscribesOnDisconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := stream.NewHub(stream.NewMemoryBroker())
+	if err := hub.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer hub.Stop()
+	svc := &hubStreamService{hub: hub, subs: make(chan *stream.Subscription, 1)}
+
+	g := gin.New()
+	g.GET("/stream", func(ctx *gin.Context) {
+		ctx.Set(middleware.CLAIM_ACCESS, model.AccessClaim{UserID: 1})
+	}, NewStreamHandler(svc).Stream)
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	reqCtx, disconnect := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"/stream?photo_id=7", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /stream: %v", err)
+	}
+	defer res.Body.Close()
+	sub := <-svc.subs
+
+	if err := hub.Publish(context.Background(), stream.PhotoTopic(7), stream.TYPE_COMMENT, map[string]int{"id": 3}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event:"+stream.TYPE_COMMENT) {
+		t.Fatalf("got %q (%v), want the comment event", line, err)
+	}
+
+	disconnect()
+
+	// the handler closes the subscription once it notices the client left
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("subscription still open after the client disconnected")
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/geedotrar/mygram/internal/metrics"
 	"github.com/geedotrar/mygram/internal/middleware"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/service"
-	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,9 +20,18 @@ type UserHandler interface {
 	GetUsersByID(ctx *gin.Context)
 	EditUser(ctx *gin.Context)
 	DeleteUsersById(ctx *gin.Context)
+	UpdateUserRole(ctx *gin.Context)
+
+	FollowUser(ctx *gin.Context)
+	UnfollowUser(ctx *gin.Context)
+	GetFollowers(ctx *gin.Context)
+	GetFollowing(ctx *gin.Context)
+	GetMentions(ctx *gin.Context)
 
 	UserSignUp(ctx *gin.Context)
 	UserLogin(ctx *gin.Context)
+	RefreshToken(ctx *gin.Context)
+	UserLogout(ctx *gin.Context)
 }
 
 type userHandlerImpl struct {
@@ -33,13 +45,15 @@ func NewUserHandler(svc service.UserService) UserHandler {
 }
 
 func (u *userHandlerImpl) GetUsers(ctx *gin.Context) {
-	users, err := u.svc.GetUsers(ctx)
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
-	if len(users) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"message": "No user found"})
+
+	users, err := u.svc.GetUsers(ctx, params)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, users)
@@ -49,16 +63,12 @@ func (u *userHandlerImpl) GetUsersByID(ctx *gin.Context) {
 	// get id user
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
-	user, err := u.svc.GetUsersByID(ctx, uint64(id))
+	user, err := u.svc.GetUserProfile(ctx, uint64(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
-		return
-	}
-	if user.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "user not found"})
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, user)
@@ -66,17 +76,17 @@ func (u *userHandlerImpl) GetUsersByID(ctx *gin.Context) {
 
 func (u *userHandlerImpl) UserSignUp(ctx *gin.Context) {
 	userSignUp := model.UserSignUp{}
-	if err := ctx.Bind(&userSignUp); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+	if err := ctx.ShouldBind(&userSignUp); err != nil {
+		ctx.Error(bindError(err))
 		return
 	}
 	if err := userSignUp.Validate(); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 	user, err := u.svc.SignUp(ctx, userSignUp)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, map[string]any{
@@ -87,61 +97,86 @@ func (u *userHandlerImpl) UserSignUp(ctx *gin.Context) {
 func (u *userHandlerImpl) UserLogin(ctx *gin.Context) {
 	var userLogin model.UserLogin
 
-	if err := ctx.Bind(&userLogin); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: err.Error()})
+	if err := ctx.ShouldBind(&userLogin); err != nil {
+		ctx.Error(bindError(err))
 		return
 	}
 
 	// Memeriksa kredensial pengguna
 	user, err := u.svc.CheckCredentials(ctx, userLogin.Email, userLogin.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			metrics.Logins.WithLabelValues(metrics.LOGIN_FAILURE).Inc()
+		} else {
+			metrics.Logins.WithLabelValues(metrics.LOGIN_ERROR).Inc()
+		}
+		ctx.Error(err)
 		return
 	}
 
-	// Menghasilkan token akses untuk pengguna yang berhasil login
-	token, err := u.svc.GenerateUserAccessToken(ctx, user)
+	// Menghasilkan token akses dan refresh token untuk pengguna yang berhasil login
+	token, err := u.svc.GenerateUserTokens(ctx, user)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		metrics.Logins.WithLabelValues(metrics.LOGIN_ERROR).Inc()
+		ctx.Error(err)
 		return
 	}
+	metrics.Logins.WithLabelValues(metrics.LOGIN_SUCCESS).Inc()
 
-	// Mengirimkan token akses sebagai respons ke klien
-	ctx.JSON(http.StatusOK, gin.H{"token": token})
+	// Mengirimkan token sebagai respons ke klien
+	ctx.JSON(http.StatusOK, token)
 }
 
-func (u *userHandlerImpl) EditUser(ctx *gin.Context) {
+func (u *userHandlerImpl) RefreshToken(ctx *gin.Context) {
+	var refreshToken model.RefreshToken
 
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+	if err := ctx.ShouldBindJSON(&refreshToken); err != nil {
+		ctx.Error(bindError(err))
 		return
 	}
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user session"})
+
+	token, err := u.svc.RefreshUserTokens(ctx, refreshToken.RefreshToken)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	userIdInt, ok := userId.(float64)
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+func (u *userHandlerImpl) UserLogout(ctx *gin.Context) {
+	claim, ok := middleware.GetAccessClaim(ctx)
 	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid user id session"})
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+
+	if err := u.svc.RevokeSession(ctx, claim.SessionID); err != nil {
+		ctx.Error(err)
 		return
 	}
-	if id != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user request"})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "You have been successfully logged out"})
+}
+
+func (u *userHandlerImpl) EditUser(ctx *gin.Context) {
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 	// Parse user data from request body
 	var user model.User
 	if err := ctx.ShouldBindJSON(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "Invalid request body"})
+		ctx.Error(bindError(err))
 		return
 	}
 
 	// Call service to edit user data
 	updatedUser, err := u.svc.EditUser(ctx, uint64(id), user)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -149,41 +184,135 @@ func (u *userHandlerImpl) EditUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, updatedUser)
 }
 
+func (u *userHandlerImpl) UpdateUserRole(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id == 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	var userRole model.UserRole
+	if err := ctx.ShouldBindJSON(&userRole); err != nil {
+		ctx.Error(bindError(err))
+		return
+	}
+	if !model.IsValidRole(userRole.Role) {
+		ctx.Error(service.ErrInvalidRole)
+		return
+	}
+
+	user, err := u.svc.UpdateUserRole(ctx, uint64(id), userRole.Role)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
 func (u *userHandlerImpl) DeleteUsersById(ctx *gin.Context) {
 	// get id user
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if id == 0 || err != nil {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid required param"})
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
 
-	// check user id session from context
-	userId, ok := ctx.Get(middleware.CLAIM_USER_ID)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user session"})
+	user, err := u.svc.DeleteUsersById(ctx, uint64(id))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	userIdInt, ok := userId.(float64)
+	ctx.JSON(http.StatusOK, map[string]any{
+		"user":    user,
+		"message": "Your account has been successfully deleted",
+	})
+}
+
+func (u *userHandlerImpl) FollowUser(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id <= 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	claim, ok := middleware.GetAccessClaim(ctx)
 	if !ok {
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Message: "invalid user id session"})
+		ctx.Error(middleware.ErrInvalidSession)
 		return
 	}
-	if id != int(userIdInt) {
-		ctx.JSON(http.StatusUnauthorized, response.ErrorResponse{Message: "invalid user request"})
+
+	if err := u.svc.FollowUser(ctx, claim.UserID, uint64(id)); err != nil {
+		ctx.Error(err)
 		return
 	}
+	ctx.JSON(http.StatusCreated, map[string]any{
+		"message": "user followed",
+	})
+}
 
-	user, err := u.svc.DeleteUsersById(ctx, uint64(id))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Message: err.Error()})
+func (u *userHandlerImpl) UnfollowUser(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id <= 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
 		return
 	}
-	if user.ID == 0 {
-		ctx.JSON(http.StatusNotFound, response.ErrorResponse{Message: "user not found"})
+	claim, ok := middleware.GetAccessClaim(ctx)
+	if !ok {
+		ctx.Error(middleware.ErrInvalidSession)
+		return
+	}
+
+	if err := u.svc.UnfollowUser(ctx, claim.UserID, uint64(id)); err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, map[string]any{
-		"user":    user,
-		"message": "Your account has been successfully deleted",
+		"message": "user unfollowed",
 	})
 }
+
+func (u *userHandlerImpl) GetFollowers(ctx *gin.Context) {
+	u.listFollows(ctx, u.svc.GetFollowers)
+}
+
+func (u *userHandlerImpl) GetFollowing(ctx *gin.Context) {
+	u.listFollows(ctx, u.svc.GetFollowing)
+}
+
+func (u *userHandlerImpl) listFollows(ctx *gin.Context, list func(context.Context, uint64, pagination.Params) (pagination.Page[model.FollowUser], error)) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id <= 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	users, err := list(ctx, uint64(id), params)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, users)
+}
+
+func (u *userHandlerImpl) GetMentions(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if id <= 0 || err != nil {
+		ctx.Error(middleware.ErrInvalidParam)
+		return
+	}
+	params, err := pagination.NewParams(ctx.Query("limit"), ctx.Query("cursor"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	mentions, err := u.svc.GetMentions(ctx, uint64(id), params)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, mentions)
+}
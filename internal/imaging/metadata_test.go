@@ -0,0 +1,303 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const gpsSecret = "GPS 52.3676N 4.9041E"
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 60), G: uint8(y * 60), B: 90, A: 255})
+		}
+	}
+	return img
+}
+
+// jpegSegment wraps payload in a marker segment.
+func jpegSegment(marker byte, payload string) []byte {
+	b := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+// exifPayload is an APP1 EXIF payload with the given orientation and a GPS
+// IFD pointer, followed by text standing in for the GPS position.
+func exifPayload(order binary.ByteOrder, orientation uint16) string {
+	tiff := make([]byte, 8+2+2*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 0x2A)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 2)
+	// GPS IFD pointer, then the orientation
+	order.PutUint16(tiff[10:], 0x8825)
+	order.PutUint16(tiff[12:], 4)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint32(tiff[18:], 38)
+	order.PutUint16(tiff[22:], 0x0112)
+	order.PutUint16(tiff[24:], 3)
+	order.PutUint32(tiff[26:], 1)
+	order.PutUint16(tiff[30:], orientation)
+	return "Exif\x00\x00" + string(tiff) + gpsSecret
+}
+
+func encodeJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func pngChunk(typ, data string) []byte {
+	b := make([]byte, 4, 12+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	b = append(b, typ...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE([]byte(typ+data)))
+}
+
+func riffChunk(fourCC, data string) []byte {
+	b := append([]byte(fourCC), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, chunk := range chunks {
+		b = append(b, chunk...)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestStripMetadata(t *testing.T) {
+	encoded := encodeJPEG(t)
+	soi, scan := encoded[:2], encoded[2:]
+	jfif := jpegSegment(0xE0, "JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	icc := jpegSegment(0xE2, "ICC_PROFILE\x00\x01\x01profile")
+	xmp := jpegSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+gpsSecret+"</x:xmpmeta>")
+	iptc := jpegSegment(0xED, "Photoshop 3.0\x00"+gpsSecret)
+	comment := jpegSegment(0xFE, gpsSecret)
+
+	pngData := encodePNG(t)
+	// IHDR is the first chunk after the 8 byte signature
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(pngData[8:]))
+	pngHead, pngRest := pngData[:ihdrEnd], pngData[ihdrEnd:]
+
+	vp8x := func(flags byte) []byte {
+		return riffChunk("VP8X", string([]byte{flags, 0, 0, 0, 3, 0, 0, 3, 0, 0}))
+	}
+	vp8l := riffChunk("VP8L", "pixels")
+	alph := riffChunk("ALPH", "odd")
+
+	tests := []struct {
+		name        string
+		contentType string
+		in          []byte
+		want        []byte
+	}{
+		{
+			name:        "jpeg keeps the orientation alone",
+			contentType: "image/jpeg",
+			in:          join(soi, jfif, jpegSegment(0xE1, exifPayload(binary.LittleEndian, 6)), xmp, iptc, comment, icc, scan),
+			want:        join(soi, jfif, orientationSegment(6), icc, scan),
+		},
+		{
+			name:        "jpeg with big endian EXIF and no JFIF",
+			contentType: "image/jpeg",
+			in:          join(soi, jpegSegment(0xE1, exifPayload(binary.BigEndian, 3)), scan),
+			want:        join(soi, orientationSegment(3), scan),
+		},
+		{
+			name:        "jpeg upright drops EXIF entirely",
+			contentType: "image/jpeg",
+			in:          join(soi, jfif, jpegSegment(0xE1, exifPayload(binary.LittleEndian, 1)), xmp, scan),
+			want:        join(soi, jfif, scan),
+		},
+		{
+			name:        "jpeg ignores an invalid orientation",
+			contentType: "image/jpeg",
+			in:          join(soi, jpegSegment(0xE1, exifPayload(binary.LittleEndian, 9)), scan),
+			want:        join(soi, scan),
+		},
+		{
+			name:        "jpeg without metadata is unchanged",
+			contentType: "image/jpeg",
+			in:          encoded,
+			want:        encoded,
+		},
+		{
+			name:        "png",
+			contentType: "image/png",
+			in: join(pngHead,
+				pngChunk("eXIf", exifPayload(binary.BigEndian, 6)[6:]),
+				pngChunk("tEXt", "Comment\x00"+gpsSecret),
+				pngChunk("zTXt", "Comment\x00\x00"+gpsSecret),
+				pngChunk("iTXt", "XML:com.adobe.xmp\x00\x00\x00\x00\x00"+gpsSecret),
+				pngChunk("tIME", "\x07\xe8\x05\x01\x0c\x00\x00"),
+				pngRest),
+			want: pngData,
+		},
+		{
+			name:        "webp clears the VP8X flags",
+			contentType: "image/webp",
+			in:          webpFile(vp8x(0x10|0x08|0x04), alph, vp8l, riffChunk("EXIF", exifPayload(binary.LittleEndian, 6)[6:]), riffChunk("XMP ", "<x:xmpmeta>"+gpsSecret+"</x:xmpmeta>")),
+			want:        webpFile(vp8x(0x10), alph, vp8l),
+		},
+		{
+			name:        "other types are left alone",
+			contentType: "image/gif",
+			in:          []byte("GIF89a" + gpsSecret),
+			want:        []byte("GIF89a" + gpsSecret),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripMetadata(tt.in, tt.contentType)
+			if err != nil {
+				t.Fatalf("StripMetadata: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got\n% x\nwant\n% x", got, tt.want)
+			}
+			if tt.contentType != "image/gif" && bytes.Contains(got, []byte(gpsSecret)) {
+				t.Error("metadata left in the output")
+			}
+		})
+	}
+}
+
+func TestStripMetadataKeepsImagesDecodable(t *testing.T) {
+	encoded := encodeJPEG(t)
+	withExif := join(encoded[:2], jpegSegment(0xE1, exifPayload(binary.LittleEndian, 8)), encoded[2:])
+	pngData := encodePNG(t)
+	withText := join(pngData[:33], pngChunk("tEXt", "Comment\x00"+gpsSecret), pngData[33:])
+
+	for _, in := range []struct {
+		contentType string
+		data        []byte
+	}{{"image/jpeg", withExif}, {"image/png", withText}} {
+		out, err := StripMetadata(in.data, in.contentType)
+		if err != nil {
+			t.Fatalf("%s: StripMetadata: %v", in.contentType, err)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+			t.Errorf("%s: cannot decode the stripped image: %v", in.contentType, err)
+		}
+	}
+	if out, _ := StripMetadata(withExif, "image/jpeg"); Orientation(out) != 8 {
+		t.Errorf("got orientation %d after stripping, want 8", Orientation(out))
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	encoded := encodeJPEG(t)
+	withExif := func(payload string) []byte {
+		return join(encoded[:2], jpegSegment(0xE1, payload), encoded[2:])
+	}
+	tests := []struct {
+		name string
+		in   []byte
+		want int
+	}{
+		{"none", encoded, 1},
+		{"little endian", withExif(exifPayload(binary.LittleEndian, 6)), 6},
+		{"big endian", withExif(exifPayload(binary.BigEndian, 3)), 3},
+		{"out of range", withExif(exifPayload(binary.LittleEndian, 0)), 1},
+		{"unknown byte order", withExif("Exif\x00\x00XX\x00\x2A\x00\x00\x00\x08"), 1},
+		{"IFD past the end", withExif("Exif\x00\x00MM\x00\x2A\xFF\xFF\xFF\xF0"), 1},
+		{"entries past the end", withExif("Exif\x00\x00MM\x00\x2A\x00\x00\x00\x08\x00\x09"), 1},
+		{"short TIFF header", withExif("Exif\x00\x00MM"), 1},
+		{"XMP only", withExif("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"), 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Orientation(tt.in); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripMetadataMalformed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		in          []byte
+	}{
+		{"empty jpeg", "image/jpeg", nil},
+		{"jpeg without SOI", "image/jpeg", []byte("\x00\x00\xFF\xDA\x00\x00")},
+		{"jpeg with only SOI", "image/jpeg", []byte("\xFF\xD8")},
+		{"jpeg segment past the end", "image/jpeg", []byte("\xFF\xD8\xFF\xE1\x10\x00Exif")},
+		{"jpeg segment shorter than its length", "image/jpeg", []byte("\xFF\xD8\xFF\xE1\x00\x01\xFF\xDA\x00")},
+		{"jpeg garbage between segments", "image/jpeg", []byte("\xFF\xD8\xFF\xE0\x00\x02\x00\xFF\xDA\x00")},
+		{"jpeg without image data", "image/jpeg", []byte("\xFF\xD8\xFF\xE0\x00\x02")},
+		{"png signature", "image/png", []byte("\x89PNG\r\n")},
+		{"png truncated chunk header", "image/png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0DIHDR")},
+		{"png chunk past the end", "image/png", join([]byte("\x89PNG\r\n\x1a\n"), []byte("\xFF\xFF\xFF\xF0tEXt\x00\x00\x00\x00"))},
+		{"webp header", "image/webp", []byte("RIFF\x04\x00\x00\x00WEB")},
+		{"webp not RIFF", "image/webp", []byte("RIFX\x04\x00\x00\x00WEBP")},
+		{"webp truncated chunk header", "image/webp", []byte("RIFF\x08\x00\x00\x00WEBPVP8")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StripMetadata(tt.in, tt.contentType); !errors.Is(err, ErrMalformedImage) {
+				t.Errorf("got error %v, want ErrMalformedImage", err)
+			}
+		})
+	}
+}
+
+// Every prefix of a valid file is either accepted or rejected as malformed,
+// never a panic.
+func TestStripMetadataTruncated(t *testing.T) {
+	encoded := encodeJPEG(t)
+	pngData := encodePNG(t)
+	files := map[string][]byte{
+		"image/jpeg": join(encoded[:2], jpegSegment(0xE1, exifPayload(binary.LittleEndian, 6)), encoded[2:]),
+		"image/png":  join(pngData[:33], pngChunk("eXIf", exifPayload(binary.BigEndian, 6)[6:]), pngData[33:]),
+		"image/webp": webpFile(riffChunk("VP8X", "\x08\x00\x00\x00\x03\x00\x00\x03\x00\x00"), riffChunk("VP8L", "pixels"), riffChunk("EXIF", gpsSecret)),
+	}
+	for contentType, data := range files {
+		for n := 0; n < len(data); n++ {
+			if _, err := StripMetadata(data[:n], contentType); err != nil && !errors.Is(err, ErrMalformedImage) {
+				t.Fatalf("%s cut at %d: got error %v, want ErrMalformedImage", contentType, n, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/geedotrar/mygram/pkg/logger"
+)
+
+// Pool runs photo processing jobs on a fixed number of goroutines so uploads
+// return as soon as the original is stored.
+type Pool struct {
+	jobs    chan uint64
+	workers int
+	wg      sync.WaitGroup
+	running atomic.Bool
+	cancel  context.CancelFunc
+}
+
+func NewPool(workers int, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		jobs:    make(chan uint64, queueSize),
+		workers: workers,
+	}
+}
+
+// Start launches the workers, each calling process for the queued photo IDs.
+func (p *Pool) Start(process func(ctx context.Context, photoID uint64) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.running.Store(true)
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for id := range p.jobs {
+				ctx := logger.With(ctx, "photo_id", id)
+				if err := process(ctx, id); err != nil {
+					logger.FromContext(ctx).Error("cannot process photo", "error", err)
+				}
+			}
+		}()
+	}
+}
+
+// Enqueue schedules a photo without blocking. It reports false when the
+// queue is full or the pool is stopped; the photo then stays pending.
+func (p *Pool) Enqueue(photoID uint64) (ok bool) {
+	if !p.running.Load() {
+		return false
+	}
+	defer func() {
+		// the queue was closed by a concurrent Stop
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	select {
+	case p.jobs <- photoID:
+		return true
+	default:
+		return false
+	}
+}
+
+// Running reports whether the workers have been started and not stopped.
+func (p *Pool) Running() bool {
+	return p.running.Load()
+}
+
+// Stop lets the workers finish the queued jobs. If ctx ends first the jobs
+// in flight are cancelled and Stop returns ctx.Err().
+func (p *Pool) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil
+	}
+	close(p.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the decoded size of an upload so a tiny, highly
+// compressed file cannot exhaust memory once decoded.
+const MaxPixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// VariantSpec is a resized rendition, bounded by MaxSide on its longest side.
+type VariantSpec struct {
+	Name    string
+	MaxSide int
+}
+
+// DefaultVariants are ordered from largest to smallest so each one can be
+// scaled down from the previous.
+var DefaultVariants = []VariantSpec{
+	{Name: "large", MaxSide: 1280},
+	{Name: "medium", MaxSide: 640},
+	{Name: "thumbnail", MaxSide: 200},
+}
+
+type Variant struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// CheckDimensions reads only the image header and rejects images whose
+// pixel count exceeds MaxPixels.
+func CheckDimensions(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrMalformedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+// GenerateVariants decodes the original and renders every spec. Variants
+// are re-encoded, so they carry no metadata; the EXIF orientation is applied
+// to the pixels instead. PNG and GIF sources keep transparency as PNG, the
+// rest become JPEG.
+func GenerateVariants(data []byte, contentType string, specs []VariantSpec) ([]Variant, error) {
+	if err := CheckDimensions(data); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrMalformedImage
+	}
+	orientation := 1
+	if contentType == "image/jpeg" {
+		orientation = Orientation(data)
+	}
+	asPNG := contentType == "image/png" || contentType == "image/gif"
+
+	variants := []Variant{}
+	for _, spec := range specs {
+		src = resize(src, spec.MaxSide)
+		img := orient(src, orientation)
+
+		buf := &bytes.Buffer{}
+		variant := Variant{Name: spec.Name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+		if asPNG {
+			variant.ContentType = "image/png"
+			err = png.Encode(buf, img)
+		} else {
+			variant.ContentType = "image/jpeg"
+			err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant.Data = buf.Bytes()
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// resize scales img down to fit maxSide, never up.
+func resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation so the pixels show upright.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = b.Dx()-1-x, y
+			case 3:
+				dx, dy = b.Dx()-1-x, b.Dy()-1-y
+			case 4:
+				dx, dy = x, b.Dy()-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = b.Dy()-1-y, x
+			case 7:
+				dx, dy = b.Dy()-1-y, b.Dx()-1-x
+			case 8:
+				dx, dy = y, b.Dx()-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
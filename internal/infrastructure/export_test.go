@@ -0,0 +1,9 @@
+package infrastructure
+
+import "gorm.io/gorm"
+
+// TracingPlugin lets the tests of infrastructure_test trace a connection
+// they opened themselves.
+func TracingPlugin(db string) gorm.Plugin {
+	return &tracingPlugin{db: db}
+}
@@ -0,0 +1,130 @@
+package infrastructure_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/service"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/gin-gonic/gin"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type tracedPostgres struct {
+	db *gorm.DB
+}
+
+func (p tracedPostgres) GetConnection() *gorm.DB        { return p.db }
+func (p tracedPostgres) GetReadConnection() *gorm.DB    { return p.db }
+func (p tracedPostgres) Ping(ctx context.Context) error { return nil }
+func (p tracedPostgres) Close() error                   { return nil }
+
+var _ infrastructure.GormPostgres = tracedPostgres{}
+
+func TestRequestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Install(sdktrace.NewSimpleSpanProcessor(exporter), "test", 1)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	mock.ExpectQuery(`SELECT .* FROM "social_medias"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).AddRow(4, "blog", 1))
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	if err := db.Use(infrastructure.TracingPlugin("primary")); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	svc := service.NewSocialMediaService(repository.NewSocialMediaQuery(tracedPostgres{db: db}), nil)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(middleware.Tracing())
+	g.GET("/socialmedias/:id", func(ctx *gin.Context) {
+		id, _ := strconv.ParseUint(ctx.Param("id"), 10, 64)
+		if _, err := svc.GetSocialMediaByID1(ctx.Request.Context(), id); err != nil {
+			ctx.Error(err)
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/socialmedias/4", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %v", w.Code, mock.ExpectationsWereMet())
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	request, ok := spans["GET /socialmedias/:id"]
+	if !ok {
+		t.Fatalf("no request span in %v", names(spans))
+	}
+	call, ok := spans["SocialMediaService.GetSocialMediaByID1"]
+	if !ok {
+		t.Fatalf("no service span in %v", names(spans))
+	}
+	query, ok := spans["query social_medias"]
+	if !ok {
+		t.Fatalf("no SQL span in %v", names(spans))
+	}
+
+	if request.SpanKind != trace.SpanKindServer || request.Parent.IsValid() {
+		t.Errorf("request span is a %v child of %v, want a root server span", request.SpanKind, request.Parent.SpanID())
+	}
+	if call.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Error("service span is not a child of the request span")
+	}
+	if query.Parent.SpanID() != call.SpanContext.SpanID() {
+		t.Error("SQL span is not a child of the service span")
+	}
+	if query.SpanKind != trace.SpanKindClient {
+		t.Errorf("SQL span is a %v span, want a client span", query.SpanKind)
+	}
+	for _, span := range []tracetest.SpanStub{call, query} {
+		if span.SpanContext.TraceID() != request.SpanContext.TraceID() {
+			t.Errorf("%s is in another trace", span.Name)
+		}
+	}
+	text := ""
+	for _, attr := range query.Attributes {
+		if attr.Key == "db.query.text" {
+			text = attr.Value.AsString()
+		}
+	}
+	if text == "" {
+		t.Error("SQL span has no query text")
+	}
+}
+
+func names(spans map[string]tracetest.SpanStub) []string {
+	out := []string{}
+	for name := range spans {
+		out = append(out, name)
+	}
+	return out
+}
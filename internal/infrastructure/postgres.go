@@ -1,42 +1,214 @@
 package infrastructure
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/geedotrar/mygram/internal/config"
+	"github.com/geedotrar/mygram/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// maxConnectBackoff caps the wait between two connection attempts.
+const maxConnectBackoff = 10 * time.Second
+
 type GormPostgres interface {
+	// GetConnection is the primary, for writes and for reads that must see
+	// them.
 	GetConnection() *gorm.DB
+	// GetReadConnection is a healthy replica, or the primary when there is
+	// none. Replicas may lag behind, so it only suits listings.
+	GetReadConnection() *gorm.DB
+	// Ping checks the primary and the replicas. Replicas failing it leave
+	// the read rotation until they answer again; only a failing primary is
+	// reported, since reads fall back to it.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type replica struct {
+	db      *gorm.DB
+	healthy atomic.Bool
 }
 
 type gormPostgresImpl struct {
-	master *gorm.DB
+	master   *gorm.DB
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
+	wg       sync.WaitGroup
 }
 
-func NewGormPostgres() GormPostgres {
-	return &gormPostgresImpl{
-		master: connect(),
+// NewGormPostgres connects to the primary and the replicas of cfg, retrying
+// each with exponential backoff for up to cfg.ConnectTimeout, so the server
+// can start before the database is up.
+func NewGormPostgres(cfg config.Database) (GormPostgres, error) {
+	master, err := connect(cfg, cfg.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the primary: %w", err)
+	}
+	g := &gormPostgresImpl{master: master, stop: make(chan struct{})}
+	if err := instrument(master, "primary"); err != nil {
+		g.Close()
+		return nil, fmt.Errorf("instrumenting the primary: %w", err)
+	}
+	for i, dsn := range cfg.ReplicaDSNs {
+		db, err := connect(cfg, dsn)
+		if err != nil {
+			g.Close()
+			return nil, fmt.Errorf("connecting to replica %d: %w", i+1, err)
+		}
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		g.replicas = append(g.replicas, r)
+		if err := instrument(db, fmt.Sprintf("replica_%d", i+1)); err != nil {
+			g.Close()
+			return nil, fmt.Errorf("instrumenting replica %d: %w", i+1, err)
+		}
+	}
+
+	if len(g.replicas) > 0 && cfg.HealthCheckInterval > 0 {
+		g.wg.Add(1)
+		go g.checkHealth(cfg.HealthCheckInterval)
 	}
+	return g, nil
 }
 
-func connect() *gorm.DB {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
+func connect(cfg config.Database, dsn string) (*gorm.DB, error) {
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: newGormLogger()})
+		if err == nil {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, err
+			}
+			sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+			sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+			sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+			sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+			return db, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("database is not reachable, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
 
-	connectionString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	db, err := gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+// instrument registers the metrics and tracing plugins on db and exposes
+// the stats of its connection pool.
+func instrument(db *gorm.DB, name string) error {
+	if err := db.Use(&metricsPlugin{db: name}); err != nil {
+		return err
+	}
+	if err := db.Use(&tracingPlugin{db: name}); err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return db
+	return metrics.Register(collectors.NewDBStatsCollector(sqlDB, name))
 }
 
 func (g *gormPostgresImpl) GetConnection() *gorm.DB {
 	return g.master
 }
+
+// GetReadConnection spreads reads over the healthy replicas in turn.
+func (g *gormPostgresImpl) GetReadConnection() *gorm.DB {
+	n := len(g.replicas)
+	if n == 0 {
+		return g.master
+	}
+	start := g.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := g.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return g.master
+}
+
+func (g *gormPostgresImpl) Ping(ctx context.Context) error {
+	for i, r := range g.replicas {
+		err := ping(ctx, r.db)
+		if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.Info("replica is back in the read rotation", "replica", i+1)
+			} else {
+				slog.Warn("replica left the read rotation", "replica", i+1, "error", err)
+			}
+		}
+	}
+	if err := ping(ctx, g.master); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	return nil
+}
+
+// Close stops the health checks and closes every connection pool.
+func (g *gormPostgresImpl) Close() error {
+	select {
+	case <-g.stop:
+		return nil
+	default:
+		close(g.stop)
+	}
+	g.wg.Wait()
+
+	errs := []error{}
+	for _, db := range append([]*gorm.DB{g.master}, g.replicaDBs()...) {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (g *gormPostgresImpl) checkHealth(interval time.Duration) {
+	defer g.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			g.Ping(ctx)
+			cancel()
+		}
+	}
+}
+
+func (g *gormPostgresImpl) replicaDBs() []*gorm.DB {
+	dbs := make([]*gorm.DB, 0, len(g.replicas))
+	for _, r := range g.replicas {
+		dbs = append(dbs, r.db)
+	}
+	return dbs
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "mygram"
+
+const (
+	LOGIN_SUCCESS = "success"
+	LOGIN_FAILURE = "failure"
+	LOGIN_ERROR   = "error"
+
+	TOKEN_MISSING = "missing"
+	TOKEN_SCHEME  = "scheme"
+	TOKEN_INVALID = "invalid"
+	TOKEN_SESSION = "session"
+)
+
+// Registry holds every metric served by Handler, along with the Go runtime
+// and process ones.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time taken by database queries, by connection, operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"db", "operation", "table"})
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "db_query_errors_total",
+		Help:      "Database queries that failed, by connection, operation and table. Missing records are not errors.",
+	}, []string{"db", "operation", "table"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "auth_logins_total",
+		Help:      "Login attempts, by result: success, failure for bad credentials or error.",
+	}, []string{"result"})
+	TokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "auth_token_validation_failures_total",
+		Help:      "Bearer tokens rejected, by reason: missing, scheme, invalid or session.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		DBQueryDuration,
+		DBQueryErrors,
+		Logins,
+		TokenValidationFailures,
+	)
+}
+
+// Register adds c to Registry. Registering the same collector twice is not
+// an error.
+func Register(c prometheus.Collector) error {
+	err := Registry.Register(c)
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
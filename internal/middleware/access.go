@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OwnerFunc resolves the ID of the user owning the resource a request
+// targets. It returns an apperror.ErrNotFound when there is no such
+// resource.
+type OwnerFunc func(ctx *gin.Context) (uint64, error)
+
+// ParamOwner is an OwnerFunc for routes where the path param is the user ID
+// itself, such as /users/:id.
+func ParamOwner(param string) OwnerFunc {
+	return func(ctx *gin.Context) (uint64, error) {
+		id, err := strconv.ParseUint(ctx.Param(param), 10, 64)
+		if id == 0 || err != nil {
+			return 0, ErrInvalidParam
+		}
+		return id, nil
+	}
+}
+
+// RequireRole lets the request through only if the caller has one of roles.
+// It must run after CheckAuthBearer.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claim, ok := GetAccessClaim(ctx)
+		if !ok {
+			abort(ctx, ErrInvalidSession)
+			return
+		}
+		if !hasRole(claim.Role, roles) {
+			abort(ctx, ErrForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireOwnerOr lets the request through if the caller owns the resource
+// or has one of roles. With no roles it is an owner-only check. It must run
+// after CheckAuthBearer.
+func RequireOwnerOr(owner OwnerFunc, roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claim, ok := GetAccessClaim(ctx)
+		if !ok {
+			abort(ctx, ErrInvalidSession)
+			return
+		}
+
+		ownerID, err := owner(ctx)
+		if err != nil {
+			abort(ctx, err)
+			return
+		}
+
+		if ownerID != claim.UserID && !hasRole(claim.Role, roles) {
+			abort(ctx, ErrForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func hasRole(role string, roles []string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	alice     = &model.AccessClaim{UserID: 1, Role: model.RoleUser}
+	bob       = &model.AccessClaim{UserID: 2, Role: model.RoleUser}
+	moderator = &model.AccessClaim{UserID: 3, Role: model.RoleModerator}
+	admin     = &model.AccessClaim{UserID: 4, Role: model.RoleAdmin}
+)
+
+// serveAccess runs access on GET path as claim, returning the response and
+// whether the handler behind it was reached.
+func serveAccess(t *testing.T, route string, path string, claim *model.AccessClaim, access gin.HandlerFunc) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(RenderErrors())
+	reached := false
+	g.GET(route, func(ctx *gin.Context) {
+		// stands in for CheckAuthBearer
+		if claim != nil {
+			ctx.Set(CLAIM_ACCESS, *claim)
+		}
+	}, access, func(ctx *gin.Context) {
+		reached = true
+		ctx.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w, reached
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body response.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode %q: %v", w.Body.String(), err)
+	}
+	return body.Code
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		claim  *model.AccessClaim
+		status int
+		code   string
+	}{
+		{"moderator", moderator, http.StatusNoContent, ""},
+		{"admin", admin, http.StatusNoContent, ""},
+		{"user", alice, http.StatusForbidden, apperror.CODE_FORBIDDEN},
+		{"no claim", nil, http.StatusUnauthorized, "invalid_session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, reached := serveAccess(t, "/reports", "/reports", tt.claim, RequireRole(model.RoleModerator, model.RoleAdmin))
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+			if reached != (tt.status == http.StatusNoContent) {
+				t.Errorf("handler reached: %v", reached)
+			}
+			if tt.code != "" && errorCode(t, w) != tt.code {
+				t.Errorf("got code %q, want %q", errorCode(t, w), tt.code)
+			}
+		})
+	}
+}
+
+func TestRequireOwnerOr(t *testing.T) {
+	errDatabase := errors.New("connection refused")
+	// photos 10 and 11 belong to alice; anything else does not exist
+	photoOwner := func(ctx *gin.Context) (uint64, error) {
+		switch ctx.Param("id") {
+		case "10", "11":
+			return alice.UserID, nil
+		case "500":
+			return 0, errDatabase
+		case "x":
+			return 0, ErrInvalidParam
+		}
+		return 0, apperror.NotFound("photo_not_found", "photo not found")
+	}
+
+	tests := []struct {
+		name   string
+		owner  OwnerFunc
+		roles  []string
+		claim  *model.AccessClaim
+		path   string
+		status int
+		code   string
+	}{
+		{"owner", photoOwner, []string{model.RoleModerator, model.RoleAdmin}, alice, "/items/10", http.StatusNoContent, ""},
+		{"other user", photoOwner, []string{model.RoleModerator, model.RoleAdmin}, bob, "/items/10", http.StatusForbidden, apperror.CODE_FORBIDDEN},
+		{"moderator bypass", photoOwner, []string{model.RoleModerator, model.RoleAdmin}, moderator, "/items/11", http.StatusNoContent, ""},
+		{"admin bypass", photoOwner, []string{model.RoleModerator, model.RoleAdmin}, admin, "/items/11", http.StatusNoContent, ""},
+		{"moderator without bypass", photoOwner, []string{model.RoleAdmin}, moderator, "/items/11", http.StatusForbidden, apperror.CODE_FORBIDDEN},
+		{"owner only", photoOwner, nil, admin, "/items/10", http.StatusForbidden, apperror.CODE_FORBIDDEN},
+		{"not found", photoOwner, []string{model.RoleAdmin}, admin, "/items/99", http.StatusNotFound, "photo_not_found"},
+		{"invalid param", photoOwner, []string{model.RoleAdmin}, alice, "/items/x", http.StatusBadRequest, "invalid_param"},
+		{"owner lookup fails", photoOwner, []string{model.RoleAdmin}, alice, "/items/500", http.StatusInternalServerError, apperror.CODE_INTERNAL},
+		{"no claim", photoOwner, []string{model.RoleAdmin}, nil, "/items/10", http.StatusUnauthorized, "invalid_session"},
+		{"param owner", ParamOwner("id"), nil, bob, "/items/2", http.StatusNoContent, ""},
+		{"param owner other user", ParamOwner("id"), nil, bob, "/items/1", http.StatusForbidden, apperror.CODE_FORBIDDEN},
+		{"param owner zero", ParamOwner("id"), nil, bob, "/items/0", http.StatusBadRequest, "invalid_param"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, reached := serveAccess(t, "/items/:id", tt.path, tt.claim, RequireOwnerOr(tt.owner, tt.roles...))
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+			if reached != (tt.status == http.StatusNoContent) {
+				t.Errorf("handler reached: %v", reached)
+			}
+			if tt.code != "" && errorCode(t, w) != tt.code {
+				t.Errorf("got code %q, want %q", errorCode(t, w), tt.code)
+			}
+		})
+	}
+}
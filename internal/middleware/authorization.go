@@ -2,12 +2,14 @@ package middleware
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
+	"github.com/geedotrar/mygram/internal/metrics"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/service"
 	"github.com/geedotrar/mygram/pkg/helper"
-	"github.com/geedotrar/mygram/pkg/response"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,78 +17,91 @@ const (
 	STATIC_USERNAME = "golang006awesome"
 	STATIC_PASSWORD = "mysecretpassword"
 
-	CLAIM_USER_ID  = "claim_user_id"
-	CLAIM_USERNAME = "claim_username"
+	CLAIM_ACCESS = "claim_access"
 )
 
+type AuthMiddleware interface {
+	CheckAuthBearer(ctx *gin.Context)
+}
+
+type authMiddlewareImpl struct {
+	userSvc service.UserService
+	keys    *helper.KeySet
+	policy  helper.ClaimPolicy
+}
+
+func NewAuthMiddleware(userSvc service.UserService, keys *helper.KeySet, policy helper.ClaimPolicy) AuthMiddleware {
+	return &authMiddlewareImpl{userSvc: userSvc, keys: keys, policy: policy}
+}
+
+// GetAccessClaim returns the claim CheckAuthBearer stored for this request.
+func GetAccessClaim(ctx *gin.Context) (model.AccessClaim, bool) {
+	value, ok := ctx.Get(CLAIM_ACCESS)
+	if !ok {
+		return model.AccessClaim{}, false
+	}
+	claim, ok := value.(model.AccessClaim)
+	return claim, ok
+}
+
 func CheckAuthBasic(ctx *gin.Context) {
 	auth := ctx.GetHeader("Authorization")
 
 	authArr := strings.Split(auth, " ")
 	if len(authArr) < 2 {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid token"},
-		})
+		abort(ctx, ErrInvalidToken)
 		return
 	}
 	if authArr[0] != "Basic" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid authorization method"},
-		})
+		abort(ctx, ErrInvalidAuthMethod)
 		return
 	}
 
 	token := authArr[1]
 	basic, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid token", "failed to decode"},
-		})
+		abort(ctx, ErrInvalidToken)
 		return
 	}
 
 	if string(basic) != fmt.Sprintf("%v:%v", STATIC_USERNAME, STATIC_PASSWORD) {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid username or password"},
-		})
+		abort(ctx, ErrInvalidCredentials)
 		return
 	}
 	ctx.Next()
 }
 
-func CheckAuthBearer(ctx *gin.Context) {
+func (a *authMiddlewareImpl) CheckAuthBearer(ctx *gin.Context) {
 	auth := ctx.GetHeader("Authorization")
 
 	authArr := strings.Split(auth, " ")
 	if len(authArr) < 2 {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid token"},
-		})
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TOKEN_MISSING).Inc()
+		abort(ctx, ErrInvalidToken)
 		return
 	}
 	if authArr[0] != "Bearer" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid authorization method"},
-		})
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TOKEN_SCHEME).Inc()
+		abort(ctx, ErrInvalidAuthMethod)
 		return
 	}
 
 	token := authArr[1]
-	claims, err := helper.ValidateToken(token)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.ErrorResponse{
-			Message: "unauthorized",
-			Errors:  []string{"invalid token", "failed to decode"},
-		})
+	claim := model.AccessClaim{}
+	if err := a.keys.ParseClaim(ctx, token, model.SubjectAccessToken, a.policy, &claim); err != nil {
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TOKEN_INVALID).Inc()
+		abort(ctx, errors.Join(ErrInvalidToken, err))
 		return
 	}
-	ctx.Set(CLAIM_USER_ID, claims["user_id"])
-	ctx.Set(CLAIM_USERNAME, claims["username"])
+
+	// reject tokens whose session was logged out, rotated or expired
+	if err := a.userSvc.ValidateSession(ctx, claim.SessionID, claim.Jti); err != nil {
+		metrics.TokenValidationFailures.WithLabelValues(metrics.TOKEN_SESSION).Inc()
+		abort(ctx, err)
+		return
+	}
+
+	ctx.Set(CLAIM_ACCESS, claim)
+	withUser(ctx, claim.UserID)
 	ctx.Next()
 }
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/pkg/pagination"
+	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidParam       = apperror.Validation("invalid_param", "invalid required param")
+	ErrInvalidSession     = apperror.Unauthorized("invalid_session", "invalid user session")
+	ErrForbidden          = apperror.Forbidden(apperror.CODE_FORBIDDEN, apperror.ErrForbidden.Error())
+	ErrInvalidToken       = apperror.Unauthorized("invalid_token", "invalid token")
+	ErrInvalidAuthMethod  = apperror.Unauthorized("invalid_authorization_method", "invalid authorization method")
+	ErrInvalidCredentials = apperror.Unauthorized("invalid_credentials", "invalid username or password")
+)
+
+// kinds maps every kind of apperror to its HTTP status and default code.
+var kinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{apperror.ErrNotFound, http.StatusNotFound, apperror.CODE_NOT_FOUND},
+	{apperror.ErrForbidden, http.StatusForbidden, apperror.CODE_FORBIDDEN},
+	{apperror.ErrConflict, http.StatusConflict, apperror.CODE_CONFLICT},
+	{apperror.ErrValidation, http.StatusBadRequest, apperror.CODE_VALIDATION},
+	{apperror.ErrUnauthorized, http.StatusUnauthorized, apperror.CODE_UNAUTHORIZED},
+	{apperror.ErrTooLarge, http.StatusRequestEntityTooLarge, apperror.CODE_TOO_LARGE},
+	{apperror.ErrUnsupported, http.StatusUnsupportedMediaType, apperror.CODE_UNSUPPORTED},
+}
+
+// known gives a client facing meaning to errors of packages that do not
+// know about apperror.
+var known = map[error]*apperror.Error{
+	pagination.ErrInvalidCursor: apperror.Field("cursor", pagination.ErrInvalidCursor.Error()),
+	pagination.ErrInvalidLimit:  apperror.Field("limit", pagination.ErrInvalidLimit.Error()),
+}
+
+// RenderErrors writes the response of a request whose handler or
+// middleware reported an error with ctx.Error instead of writing one. An
+// apperror is rendered with its status and code; anything else is a 500
+// whose details only reach the logs. It must run after RequestLogger,
+// Tracing and Metrics so they see the final status.
+func RenderErrors() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+		status, body := render(ctx.Errors.Last().Err)
+		ctx.JSON(status, body)
+	}
+}
+
+// abort stops the request, leaving err for RenderErrors to write.
+func abort(ctx *gin.Context, err error) {
+	ctx.Error(err)
+	ctx.Abort()
+}
+
+func render(err error) (int, response.ErrorResponse) {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		for target, e := range known {
+			if errors.Is(err, target) {
+				appErr = e
+			}
+		}
+	}
+	for _, k := range kinds {
+		if appErr == nil && errors.Is(err, k.kind) {
+			appErr = &apperror.Error{Kind: k.kind, Code: k.code, Message: k.kind.Error()}
+		}
+		if appErr == nil || appErr.Kind != k.kind {
+			continue
+		}
+		body := response.ErrorResponse{Code: appErr.Code, Message: appErr.Message}
+		for _, field := range appErr.Fields {
+			body.Fields = append(body.Fields, response.FieldError{Field: field.Field, Message: field.Message})
+		}
+		return k.status, body
+	}
+	return http.StatusInternalServerError, response.ErrorResponse{
+		Code:    apperror.CODE_INTERNAL,
+		Message: "internal server error",
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/pkg/pagination"
+	"github.com/geedotrar/mygram/pkg/response"
+)
+
+func TestRender(t *testing.T) {
+	errPhotoNotFound := apperror.NotFound("photo_not_found", "photo not found")
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   response.ErrorResponse
+	}{
+		{
+			name:   "apperror",
+			err:    errPhotoNotFound,
+			status: http.StatusNotFound,
+			body:   response.ErrorResponse{Code: "photo_not_found", Message: "photo not found"},
+		},
+		{
+			name:   "wrapped apperror",
+			err:    fmt.Errorf("loading photo 7: %w", ErrForbidden),
+			status: http.StatusForbidden,
+			body:   response.ErrorResponse{Code: apperror.CODE_FORBIDDEN, Message: apperror.ErrForbidden.Error()},
+		},
+		{
+			name:   "joined with a cause",
+			err:    errors.Join(ErrInvalidToken, errors.New("token is expired")),
+			status: http.StatusUnauthorized,
+			body:   response.ErrorResponse{Code: "invalid_token", Message: "invalid token"},
+		},
+		{
+			name:   "fields",
+			err:    apperror.Validation(apperror.CODE_VALIDATION, "invalid photo", apperror.FieldError{Field: "title", Message: "title cannot be empty"}, apperror.FieldError{Field: "photo_url", Message: "photo url cannot be empty"}),
+			status: http.StatusBadRequest,
+			body: response.ErrorResponse{Code: apperror.CODE_VALIDATION, Message: "invalid photo", Fields: []response.FieldError{
+				{Field: "title", Message: "title cannot be empty"},
+				{Field: "photo_url", Message: "photo url cannot be empty"},
+			}},
+		},
+		{
+			name:   "bare kind",
+			err:    apperror.ErrNotFound,
+			status: http.StatusNotFound,
+			body:   response.ErrorResponse{Code: apperror.CODE_NOT_FOUND, Message: apperror.ErrNotFound.Error()},
+		},
+		{
+			name:   "wrapped kind",
+			err:    fmt.Errorf("upload: %w", apperror.ErrTooLarge),
+			status: http.StatusRequestEntityTooLarge,
+			body:   response.ErrorResponse{Code: apperror.CODE_TOO_LARGE, Message: apperror.ErrTooLarge.Error()},
+		},
+		{
+			name:   "known error",
+			err:    fmt.Errorf("decoding: %w", pagination.ErrInvalidCursor),
+			status: http.StatusBadRequest,
+			body: response.ErrorResponse{Code: apperror.CODE_VALIDATION, Message: pagination.ErrInvalidCursor.Error(), Fields: []response.FieldError{
+				{Field: "cursor", Message: pagination.ErrInvalidCursor.Error()},
+			}},
+		},
+		{
+			name:   "internal",
+			err:    errors.New(`pq: relation "photos" does not exist`),
+			status: http.StatusInternalServerError,
+			body:   response.ErrorResponse{Code: apperror.CODE_INTERNAL, Message: "internal server error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := render(tt.err)
+			if status != tt.status {
+				t.Errorf("got status %d, want %d", status, tt.status)
+			}
+			if !reflect.DeepEqual(body, tt.body) {
+				t.Errorf("got body %+v, want %+v", body, tt.body)
+			}
+		})
+	}
+}
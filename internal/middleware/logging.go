@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/pkg/helper"
+	"github.com/geedotrar/mygram/pkg/logger"
+	"github.com/geedotrar/mygram/pkg/response"
+	"github.com/gin-gonic/gin"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HEADER_REQUEST_ID = "X-Request-ID"
+
+	REQUEST_ID = "request_id"
+)
+
+// requestID is what an incoming X-Request-ID must look like to be kept;
+// anything else is replaced, so it cannot forge log lines.
+var requestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// GetRequestID returns the ID RequestLogger gave this request.
+func GetRequestID(ctx *gin.Context) string {
+	return ctx.GetString(REQUEST_ID)
+}
+
+// RequestLogger tags the request with an ID, taken from X-Request-ID when
+// the client sent a valid one, and echoes it back. The request context then
+// carries a logger adding the ID, the route and the trace to every line,
+// which CheckAuthBearer extends with the user ID. Once the request is served
+// it writes one access line; at debug level the request headers are
+// included, with the sensitive ones redacted.
+func RequestLogger(base *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		id := ctx.GetHeader(HEADER_REQUEST_ID)
+		if !requestID.MatchString(id) {
+			var err error
+			if id, err = helper.GenerateRandomID(ctx.Request.Context()); err != nil {
+				id = "unknown"
+			}
+		}
+		ctx.Set(REQUEST_ID, id)
+		ctx.Header(HEADER_REQUEST_ID, id)
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		log := base.With(REQUEST_ID, id, "method", ctx.Request.Method, "route", route)
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.IsValid() {
+			log = log.With("trace_id", span.TraceID().String(), "span_id", span.SpanID().String())
+		}
+		ctx.Request = ctx.Request.WithContext(logger.NewContext(ctx.Request.Context(), log))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", ctx.Writer.Size()),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.String("user_agent", ctx.Request.UserAgent()),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", ctx.Errors.String()))
+		}
+		// the request context now holds the logger of the deepest middleware
+		log = logger.FromContext(ctx.Request.Context())
+		if log.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, headers(ctx.Request.Header))
+		}
+		log.LogAttrs(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recover turns a panic into a 500, logging it with the stack through the
+// request logger. It must run after RequestLogger, Tracing and Metrics so
+// they see the 500 instead of the panic.
+func Recover() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, err any) {
+		logger.FromContext(ctx.Request.Context()).Error("panic serving request", "panic", err, "stack", string(debug.Stack()))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response.ErrorResponse{Code: apperror.CODE_INTERNAL, Message: "internal server error"})
+	})
+}
+
+// withUser adds the user ID to the logger of the request.
+func withUser(ctx *gin.Context, userID uint64) {
+	ctx.Request = ctx.Request.WithContext(logger.With(ctx.Request.Context(), "user_id", userID))
+}
+
+func headers(header http.Header) slog.Attr {
+	attrs := make([]any, 0, len(header))
+	for name, values := range header {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.Group("headers", attrs...)
+}
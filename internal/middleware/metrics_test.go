@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geedotrar/mygram/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountsPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	// the order of cmd/main.go
+	g.Use(Tracing(), RequestLogger(slog.New(slog.NewTextHandler(io.Discard, nil))), Metrics(), Recover(), RenderErrors())
+	g.GET("/panics/:id", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	g.GET("/fails/:id", func(ctx *gin.Context) {
+		ctx.Error(ErrForbidden)
+	})
+	panics := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/panics/:id", "500")
+	fails := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/fails/:id", "403")
+	before, beforeFails := testutil.ToFloat64(panics), testutil.ToFloat64(fails)
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panics/1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", w.Code)
+	}
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fails/1", nil))
+
+	if got := testutil.ToFloat64(panics) - before; got != 1 {
+		t.Errorf("counted %v panicked requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(fails) - beforeFails; got != 1 {
+		t.Errorf("counted %v rendered errors, want 1", got)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/gin-gonic/gin"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func installExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Install(sdktrace.NewSimpleSpanProcessor(exporter), "test", 1)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	t.Cleanup(func() { shutdown(context.Background()) })
+	return exporter
+}
+
+func TestTracingContinuesTraceparent(t *testing.T) {
+	exporter := installExporter(t)
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Tracing())
+	g.GET("/photos", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/photos", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID %s, want the caller's", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" || !span.Parent.IsRemote() {
+		t.Errorf("parent span %s (remote %v), want the caller's", got, span.Parent.IsRemote())
+	}
+	if span.Name != "GET /photos" {
+		t.Errorf("span named %q", span.Name)
+	}
+}
+
+func TestTracingMarksServerErrors(t *testing.T) {
+	exporter := installExporter(t)
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Tracing())
+	g.GET("/photos", func(ctx *gin.Context) {
+		ctx.Status(http.StatusBadGateway)
+	})
+	g.GET("/comments", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/photos", nil))
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/comments", nil))
+
+	for _, span := range exporter.GetSpans() {
+		want := codes.Unset
+		if span.Name == "GET /photos" {
+			want = codes.Error
+		}
+		if span.Status.Code != want {
+			t.Errorf("%s: status %v, want %v", span.Name, span.Status.Code, want)
+		}
+	}
+}
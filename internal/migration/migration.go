@@ -0,0 +1,329 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DEFAULT_DIR is where the migrations live in the source tree, for Create.
+const DEFAULT_DIR = "internal/migration/sql"
+
+// lockID is the advisory lock held while migrating, so instances starting
+// together apply each migration once.
+const lockID = 7_236_472_601
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var (
+	ErrInvalidName     = errors.New("migration name must only contain lowercase letters, digits and underscores")
+	ErrInvalidFileName = errors.New("migration file name must be NNNN_name.up.sql or NNNN_name.down.sql with a positive version")
+	ErrMissingVersion  = errors.New("applied migration is missing from the migration files")
+)
+
+var (
+	fileName      = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Migration is a versioned schema change with the SQL applying and
+// reverting it.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration along with when it was applied, if it was.
+type Status struct {
+	Version   uint64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator migrates db with the migrations embedded in the binary.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dir, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the NNNN_name.up.sql and NNNN_name.down.sql pairs of fsys,
+// ordered by version. Any other .sql file is an error rather than a
+// migration silently left out.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint64]*Migration{}
+	scripts := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), ErrInvalidFileName)
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), ErrInvalidFileName)
+		}
+		script := fmt.Sprintf("%d.%s", version, match[3])
+		if other, ok := scripts[script]; ok {
+			return nil, fmt.Errorf("migration %d has two %s scripts: %s and %s", version, match[3], other, entry.Name())
+		}
+		scripts[script] = entry.Name()
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each in its own
+// transaction, and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := []Migration{}
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		versions, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		done := map[uint64]bool{}
+		for _, v := range versions {
+			done[v.Version] = true
+		}
+		for _, migration := range m.migrations {
+			if done[migration.Version] {
+				continue
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name).Error
+			}); err != nil {
+				return fmt.Errorf("applying %s: %w", migration, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and
+// returns the ones reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	reverted := []Migration{}
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		versions, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(versions) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration, ok := m.find(versions[i].Version)
+			if !ok {
+				return fmt.Errorf("reverting %d_%s: %w", versions[i].Version, versions[i].Name, ErrMissingVersion)
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if migration.Down != "" {
+					if err := tx.Exec(migration.Down).Error; err != nil {
+						return err
+					}
+				}
+				return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version).Error
+			}); err != nil {
+				return fmt.Errorf("reverting %s: %w", migration, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration, and any applied one whose files are
+// gone, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	versions := []appliedMigration{}
+	if db.Migrator().HasTable("schema_migrations") {
+		var err error
+		versions, err = m.applied(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	statuses := map[uint64]Status{}
+	for _, migration := range m.migrations {
+		statuses[migration.Version] = Status{Version: migration.Version, Name: migration.Name}
+	}
+	for _, v := range versions {
+		appliedAt := v.AppliedAt
+		statuses[v.Version] = Status{Version: v.Version, Name: v.Name, AppliedAt: &appliedAt}
+	}
+
+	list := make([]Status, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Pending counts the migrations not applied yet.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Create writes blank up and down scripts for a new migration to dir,
+// numbered after the newest one there, and returns their paths.
+func Create(dir string, name string) (string, string, error) {
+	if !migrationName.MatchString(name) {
+		return "", "", ErrInvalidName
+	}
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	version := uint64(1)
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	for _, path := range []string{up, down} {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = fmt.Fprintf(file, "-- %s\n", filepath.Base(path))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
+
+// locked runs fn on a single connection holding the migration lock. The
+// lock is tied to the session, so the connection must not change.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     BIGINT PRIMARY KEY,
+		name        TEXT NOT NULL,
+		applied_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`).Error
+}
+
+func (m *Migrator) applied(db *gorm.DB) ([]appliedMigration, error) {
+	versions := []appliedMigration{}
+	if err := db.
+		Table("schema_migrations").
+		Order("version").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (m *Migrator) find(version uint64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// String formats a migration like its file names, e.g. 0001_create_users.
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
@@ -0,0 +1,246 @@
+package migration
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		want  []Migration
+		err   error
+		match string
+	}{
+		{
+			name: "pairs in version order",
+			fsys: fstest.MapFS{
+				"0010_add_tags.up.sql":       file("CREATE TABLE tags ();"),
+				"0010_add_tags.down.sql":     file("DROP TABLE tags;"),
+				"0002_add_photos.up.sql":     file("CREATE TABLE photos ();"),
+				"0009_add_comments.up.sql":   file("CREATE TABLE comments ();"),
+				"0009_add_comments.down.sql": file("DROP TABLE comments;"),
+			},
+			want: []Migration{
+				{Version: 2, Name: "add_photos", Up: "CREATE TABLE photos ();"},
+				{Version: 9, Name: "add_comments", Up: "CREATE TABLE comments ();", Down: "DROP TABLE comments;"},
+				{Version: 10, Name: "add_tags", Up: "CREATE TABLE tags ();", Down: "DROP TABLE tags;"},
+			},
+		},
+		{
+			name: "unpadded version",
+			fsys: fstest.MapFS{"12_add_users.up.sql": file("up")},
+			want: []Migration{{Version: 12, Name: "add_users", Up: "up"}},
+		},
+		{
+			name: "other files and directories",
+			fsys: fstest.MapFS{
+				"0001_add_users.up.sql":     file("up"),
+				"README.md":                 file("docs"),
+				"0002_nested/0002.up.sql":   file("up"),
+				"0003_add_photos.up.sql.gz": file("up"),
+			},
+			want: []Migration{{Version: 1, Name: "add_users", Up: "up"}},
+		},
+		{
+			name: "empty",
+			fsys: fstest.MapFS{},
+			want: []Migration{},
+		},
+		{
+			name: "uppercase name",
+			fsys: fstest.MapFS{"0001_AddUsers.up.sql": file("up")},
+			err:  ErrInvalidFileName,
+		},
+		{
+			name: "no direction",
+			fsys: fstest.MapFS{"0001_add_users.sql": file("up")},
+			err:  ErrInvalidFileName,
+		},
+		{
+			name: "no version",
+			fsys: fstest.MapFS{"add_users.up.sql": file("up")},
+			err:  ErrInvalidFileName,
+		},
+		{
+			name: "version zero",
+			fsys: fstest.MapFS{"0000_add_users.up.sql": file("up")},
+			err:  ErrInvalidFileName,
+		},
+		{
+			name: "version overflow",
+			fsys: fstest.MapFS{"18446744073709551616_add_users.up.sql": file("up")},
+			err:  ErrInvalidFileName,
+		},
+		{
+			name: "two names",
+			fsys: fstest.MapFS{
+				"0001_add_users.up.sql":      file("up"),
+				"0001_create_users.down.sql": file("down"),
+			},
+			match: "migration 1 has two names",
+		},
+		{
+			name: "two up scripts",
+			fsys: fstest.MapFS{
+				"0001_add_users.up.sql": file("up"),
+				"1_add_users.up.sql":    file("up"),
+			},
+			match: "migration 1 has two up scripts",
+		},
+		{
+			name:  "no up script",
+			fsys:  fstest.MapFS{"0001_add_users.down.sql": file("down")},
+			match: "migration 0001_add_users has no up script",
+		},
+		{
+			name:  "empty up script",
+			fsys:  fstest.MapFS{"0001_add_users.up.sql": file(""), "0001_add_users.down.sql": file("down")},
+			match: "migration 0001_add_users has no up script",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				return
+			case tt.match != "":
+				if err == nil || !strings.Contains(err.Error(), tt.match) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.match)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Load: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("migration %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	dir, err := os.ReadDir("sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(nil)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	for i, m := range migrator.migrations {
+		if m.Version != uint64(i+1) {
+			t.Errorf("migration %s out of sequence at position %d", m, i)
+		}
+	}
+	if len(migrator.migrations) == 0 || len(migrator.migrations)*2 != len(dir) {
+		t.Errorf("loaded %d migrations from %d files, want an up and a down script each", len(migrator.migrations), len(dir))
+	}
+}
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+	}{
+		{"first", nil, "0001_add_users"},
+		{"after the newest", []string{"0001_add_users.up.sql", "0002_add_photos.up.sql", "0002_add_photos.down.sql"}, "0003_add_users"},
+		{"after a gap", []string{"0001_add_users.up.sql", "0007_add_photos.up.sql"}, "0008_add_users"},
+		{"past four digits", []string{"9999_add_photos.up.sql"}, "10000_add_users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.existing...)
+
+			up, down, err := Create(dir, "add_users")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if want := filepath.Join(dir, tt.want+".up.sql"); up != want {
+				t.Errorf("got up script %s, want %s", up, want)
+			}
+			if want := filepath.Join(dir, tt.want+".down.sql"); down != want {
+				t.Errorf("got down script %s, want %s", down, want)
+			}
+			for _, path := range []string{up, down} {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "-- " + filepath.Base(path) + "\n"; string(content) != want {
+					t.Errorf("%s: got %q, want %q", path, content, want)
+				}
+			}
+
+			migrations, err := Load(os.DirFS(dir))
+			if err != nil {
+				t.Fatalf("Load after Create: %v", err)
+			}
+			if newest := migrations[len(migrations)-1]; newest.String() != tt.want {
+				t.Errorf("newest migration %s, want %s", newest, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateInvalidName(t *testing.T) {
+	for _, name := range []string{"", "AddUsers", "add-users", "add users", "../add_users"} {
+		dir := t.TempDir()
+		if _, _, err := Create(dir, name); err != ErrInvalidName {
+			t.Errorf("Create(%q): got %v, want ErrInvalidName", name, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("Create(%q) wrote %d files", name, len(entries))
+		}
+	}
+}
+
+func TestCreateInvalidDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "0001_add_users.down.sql")
+	if _, _, err := Create(dir, "add_photos"); err == nil || !strings.Contains(err.Error(), "has no up script") {
+		t.Errorf("got %v, want the load error", err)
+	}
+}
+
+func TestCreateExisting(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "0001_add_users.up.sql")
+	// Load skips directories, so only the open notices this one
+	if err := os.Mkdir(filepath.Join(dir, "0002_add_photos.down.sql"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Create(dir, "add_photos"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("got %v, want fs.ErrExist", err)
+	}
+}
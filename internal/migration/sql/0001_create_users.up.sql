@@ -0,0 +1,17 @@
+CREATE TABLE users (
+    id          BIGSERIAL PRIMARY KEY,
+    username    VARCHAR(50)  NOT NULL,
+    email       VARCHAR(255) NOT NULL,
+    password    TEXT         NOT NULL,
+    role        VARCHAR(20)  NOT NULL DEFAULT 'user'
+                CHECK (role IN ('user', 'moderator', 'admin')),
+    dob         DATE         NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    deleted_at  TIMESTAMPTZ
+);
+
+-- mentions look usernames up case-insensitively
+CREATE UNIQUE INDEX users_username_key ON users (LOWER(username)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX users_created_at_id_idx ON users (created_at DESC, id DESC);
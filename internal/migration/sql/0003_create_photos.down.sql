@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS photo_variants;
+DROP TABLE IF EXISTS photos;
@@ -0,0 +1,35 @@
+CREATE TABLE photos (
+    id              BIGSERIAL PRIMARY KEY,
+    title           VARCHAR(255) NOT NULL,
+    caption         TEXT         NOT NULL DEFAULT '',
+    photo_url       TEXT         NOT NULL,
+    user_id         BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    variant_status  VARCHAR(20)  NOT NULL DEFAULT ''
+                    CHECK (variant_status IN ('', 'pending', 'ready', 'failed')),
+    storage_key     TEXT         NOT NULL DEFAULT '',
+    content_type    VARCHAR(100) NOT NULL DEFAULT '',
+    size            BIGINT       NOT NULL DEFAULT 0,
+    checksum        VARCHAR(64)  NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    deleted_at      TIMESTAMPTZ
+);
+
+CREATE INDEX photos_created_at_id_idx ON photos (created_at DESC, id DESC);
+CREATE INDEX photos_user_id_created_at_id_idx ON photos (user_id, created_at DESC, id DESC);
+-- requeued on startup
+CREATE INDEX photos_pending_idx ON photos (id) WHERE variant_status = 'pending' AND deleted_at IS NULL;
+
+CREATE TABLE photo_variants (
+    id            BIGSERIAL PRIMARY KEY,
+    photo_id      BIGINT       NOT NULL REFERENCES photos (id) ON DELETE CASCADE,
+    name          VARCHAR(20)  NOT NULL,
+    width         INTEGER      NOT NULL,
+    height        INTEGER      NOT NULL,
+    storage_key   TEXT         NOT NULL,
+    content_type  VARCHAR(100) NOT NULL,
+    size          BIGINT       NOT NULL,
+    checksum      VARCHAR(64)  NOT NULL,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (photo_id, name)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE comments (
+    id          BIGSERIAL PRIMARY KEY,
+    message     TEXT        NOT NULL,
+    user_id     BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    photo_id    BIGINT      NOT NULL REFERENCES photos (id) ON DELETE CASCADE,
+    parent_id   BIGINT      REFERENCES comments (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at  TIMESTAMPTZ
+);
+
+CREATE INDEX comments_created_at_id_idx ON comments (created_at DESC, id DESC);
+CREATE INDEX comments_photo_id_created_at_id_idx ON comments (photo_id, created_at DESC, id DESC) WHERE parent_id IS NULL;
+CREATE INDEX comments_parent_id_created_at_id_idx ON comments (parent_id, created_at DESC, id DESC) WHERE parent_id IS NOT NULL;
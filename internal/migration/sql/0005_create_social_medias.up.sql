@@ -0,0 +1,12 @@
+CREATE TABLE social_medias (
+    id                BIGSERIAL PRIMARY KEY,
+    name              VARCHAR(100) NOT NULL,
+    social_media_url  TEXT         NOT NULL,
+    user_id           BIGINT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    deleted_at        TIMESTAMPTZ
+);
+
+CREATE INDEX social_medias_created_at_id_idx ON social_medias (created_at DESC, id DESC);
+CREATE INDEX social_medias_user_id_created_at_id_idx ON social_medias (user_id, created_at DESC, id DESC);
@@ -0,0 +1,11 @@
+CREATE TABLE follows (
+    id            BIGSERIAL PRIMARY KEY,
+    follower_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    following_id  BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (follower_id, following_id),
+    CHECK (follower_id <> following_id)
+);
+
+CREATE INDEX follows_follower_id_created_at_id_idx ON follows (follower_id, created_at DESC, id DESC);
+CREATE INDEX follows_following_id_created_at_id_idx ON follows (following_id, created_at DESC, id DESC);
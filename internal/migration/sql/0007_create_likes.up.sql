@@ -0,0 +1,12 @@
+-- target_id points at photos or comments depending on target_type, so it
+-- has no foreign key; likes of deleted targets are filtered out on read.
+CREATE TABLE likes (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_type  VARCHAR(20) NOT NULL CHECK (target_type IN ('photo', 'comment')),
+    target_id    BIGINT      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, target_type, target_id)
+);
+
+CREATE INDEX likes_target_created_at_id_idx ON likes (target_type, target_id, created_at DESC, id DESC);
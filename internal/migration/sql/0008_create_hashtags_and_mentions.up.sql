@@ -0,0 +1,21 @@
+CREATE TABLE hashtags (
+    id           BIGSERIAL PRIMARY KEY,
+    tag          VARCHAR(100) NOT NULL,
+    target_type  VARCHAR(20)  NOT NULL CHECK (target_type IN ('photo', 'comment')),
+    target_id    BIGINT       NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX hashtags_tag_target_idx ON hashtags (tag, target_type, target_id);
+CREATE INDEX hashtags_target_idx ON hashtags (target_type, target_id);
+
+CREATE TABLE mentions (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_type  VARCHAR(20) NOT NULL CHECK (target_type IN ('photo', 'comment')),
+    target_id    BIGINT      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX mentions_user_id_created_at_id_idx ON mentions (user_id, created_at DESC, id DESC);
+CREATE INDEX mentions_target_idx ON mentions (target_type, target_id);
@@ -0,0 +1,14 @@
+CREATE TABLE notifications (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    actor_id     BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type         VARCHAR(20) NOT NULL,
+    target_type  VARCHAR(20) NOT NULL DEFAULT '',
+    target_id    BIGINT      NOT NULL DEFAULT 0,
+    photo_id     BIGINT      NOT NULL DEFAULT 0,
+    read_at      TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_user_id_created_at_id_idx ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE photos DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,19 @@
+-- usernames use the simple configuration: no stemming or stop words, so
+-- prefix queries match them as typed
+ALTER TABLE users
+    ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', username)) STORED;
+CREATE INDEX users_search_vector_idx ON users USING GIN (search_vector);
+
+ALTER TABLE photos
+    ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', caption), 'B')
+    ) STORED;
+CREATE INDEX photos_search_vector_idx ON photos USING GIN (search_vector);
+
+ALTER TABLE comments
+    ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('english', message)) STORED;
+CREATE INDEX comments_search_vector_idx ON comments USING GIN (search_vector);
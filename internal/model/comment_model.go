@@ -3,18 +3,26 @@ package model
 import (
 	"time"
 
+	"github.com/geedotrar/mygram/pkg/entity"
 	"gorm.io/gorm"
 )
 
 type Comment struct {
-	ID        uint64         `json:"id" gorm:"primaryKey"`
-	Message   string         `json:"message"`
-	UserID    uint64         `json:"user_id"`
-	PhotoID   uint64         `json:"photo_id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"column:deleted_at"`
-	User      struct {
+	ID        uint64          `json:"id" gorm:"primaryKey"`
+	Message   string          `json:"message"`
+	Entities  []entity.Entity `json:"entities" gorm:"-"`
+	UserID    uint64          `json:"user_id"`
+	PhotoID   uint64          `json:"photo_id"`
+	ParentID  *uint64         `json:"parent_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	DeletedAt gorm.DeletedAt  `json:"-" gorm:"column:deleted_at"`
+	// Deleted marks a removed comment kept as a placeholder because it
+	// still has replies. Its message and author are blanked out.
+	Deleted    bool  `json:"deleted,omitempty" gorm:"-"`
+	ReplyCount int64 `json:"reply_count" gorm:"->"`
+	LikeSummary
+	User struct {
 		ID       uint64 `json:"Id"`
 		Username string `json:"username"`
 		Email    string `json:"email"`
@@ -29,21 +37,26 @@ type Comment struct {
 }
 
 type CreateComment struct {
-	ID        uint64    `json:"id" `
-	Message   string    `json:"message" binding:"required"`
-	UserID    uint64    `json:"user_id"`
-	PhotoID   uint64    `json:"photo_id" binding:"required"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        uint64          `json:"id" `
+	Message   string          `json:"message" binding:"required"`
+	Entities  []entity.Entity `json:"entities" gorm:"-"`
+	UserID    uint64          `json:"user_id"`
+	PhotoID   uint64          `json:"photo_id" binding:"required"`
+	ParentID  *uint64         `json:"parent_id"`
+	CreatedAt time.Time       `json:"created_at"`
 }
 
 type GetCommentByID struct {
-	ID        uint64    `json:"id" `
-	Message   string    `json:"message" binding:"required"`
-	PhotoID   uint64    `json:"photo_id" binding:"required"`
-	UserID    uint64    `json:"user_id"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedAt time.Time `json:"created_at"`
-	User      struct {
+	ID        uint64          `json:"id" `
+	Message   string          `json:"message" binding:"required"`
+	Entities  []entity.Entity `json:"entities" gorm:"-"`
+	PhotoID   uint64          `json:"photo_id" binding:"required"`
+	ParentID  *uint64         `json:"parent_id"`
+	UserID    uint64          `json:"user_id"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	CreatedAt time.Time       `json:"created_at"`
+	LikeSummary
+	User struct {
 		ID       uint64 `json:"Id"`
 		Username string `json:"username"`
 		Email    string `json:"email"`
@@ -57,10 +70,12 @@ type GetCommentByID struct {
 	} `json:"photo,omitempty" gorm:"foreignKey:PhotoID"`
 }
 
+// UpdateComment is a comment being edited. Only the message can change.
 type UpdateComment struct {
-	ID        uint64    `json:"id" `
-	Message   string    `json:"message" binding:"required"`
-	UserID    uint64    `json:"user_id"`
-	PhotoID   uint64    `json:"photo_id" binding:"required"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint64          `json:"id" `
+	Message   string          `json:"message" binding:"required"`
+	Entities  []entity.Entity `json:"entities" gorm:"-"`
+	UserID    uint64          `json:"user_id"`
+	PhotoID   uint64          `json:"photo_id"`
+	UpdatedAt time.Time       `json:"updated_at"`
 }
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Hashtag links a photo or comment to a #tag in its text.
+type Hashtag struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	Tag        string    `json:"tag"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint64    `json:"target_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Mention links a photo or comment to a user @mentioned in its text.
+type Mention struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	UserID     uint64    `json:"user_id"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint64    `json:"target_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MentionedUser is a user mentioned by a given photo or comment.
+type MentionedUser struct {
+	TargetID uint64
+	UserID   uint64
+	Username string
+}
+
+// MentionView is one row of a user's mentions list. PhotoID is the photo
+// itself or, for comments, the photo that was commented on.
+type MentionView struct {
+	ID         uint64    `json:"id"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint64    `json:"target_id"`
+	PhotoID    uint64    `json:"photo_id"`
+	AuthorID   uint64    `json:"author_id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
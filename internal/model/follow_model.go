@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type Follow struct {
+	ID          uint64    `json:"id" gorm:"primaryKey"`
+	FollowerID  uint64    `json:"follower_id"`
+	FollowingID uint64    `json:"following_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FollowUser is one row of a followers or following list.
+type FollowUser struct {
+	FollowID   uint64    `json:"-"`
+	ID         uint64    `json:"id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type UserProfile struct {
+	User
+	FollowerCount  int64 `json:"follower_count"`
+	FollowingCount int64 `json:"following_count"`
+}
@@ -22,9 +22,22 @@ type StandardClaim struct {
 	Iat uint64 `json:"iat"`
 }
 
+const (
+	SubjectAccessToken  = "access-token"
+	SubjectRefreshToken = "refresh-token"
+)
+
 type AccessClaim struct {
 	StandardClaim
-	UserID   uint64    `json:"user_id"`
-	Username string    `json:"username"`
-	Dob      time.Time    `json:"dob"`
+	SessionID uint64    `json:"sid"`
+	UserID    uint64    `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Dob       time.Time `json:"dob"`
+}
+
+type RefreshClaim struct {
+	StandardClaim
+	SessionID uint64 `json:"sid"`
+	UserID    uint64 `json:"user_id"`
 }
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type Like struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	UserID     uint64    `json:"user_id"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint64    `json:"target_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LikeSummary is embedded in photo and comment responses. It is filled in
+// by the services, never read from the photos or comments tables.
+type LikeSummary struct {
+	LikeCount int64 `json:"like_count" gorm:"-"`
+	LikedByMe bool  `json:"liked_by_me" gorm:"-"`
+}
+
+// LikeUser is one row of a likes list.
+type LikeUser struct {
+	LikeID   uint64    `json:"-"`
+	ID       uint64    `json:"id"`
+	Username string    `json:"username"`
+	LikedAt  time.Time `json:"liked_at"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+const (
+	NotificationComment = "comment"
+	NotificationReply   = "reply"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+	NotificationMention = "mention"
+)
+
+// Notification tells UserID that ActorID commented, replied, liked,
+// followed or mentioned them. TargetType and TargetID are empty for
+// follows; PhotoID is the photo the target belongs to.
+type Notification struct {
+	ID         uint64     `json:"id" gorm:"primaryKey"`
+	UserID     uint64     `json:"-"`
+	ActorID    uint64     `json:"actor_id"`
+	Type       string     `json:"type"`
+	TargetType string     `json:"target_type,omitempty"`
+	TargetID   uint64     `json:"target_id,omitempty"`
+	PhotoID    uint64     `json:"photo_id,omitempty"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type NotificationView struct {
+	Notification  `gorm:"embedded"`
+	ActorUsername string `json:"actor_username"`
+}
+
+// NotificationPage is a page of notifications along with how many of the
+// user's notifications are unread in total.
+type NotificationPage struct {
+	pagination.Page[NotificationView]
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// ReadNotifications marks the given notifications read, or all of them
+// when IDs is empty.
+type ReadNotifications struct {
+	IDs []uint64 `json:"ids"`
+}
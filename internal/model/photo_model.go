@@ -1,65 +1,145 @@
 package model
 
 import (
-	"errors"
+	"fmt"
 	"time"
 
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/pkg/entity"
 	"gorm.io/gorm"
 )
 
+const (
+	VariantStatusPending = "pending"
+	VariantStatusReady   = "ready"
+	VariantStatusFailed  = "failed"
+)
+
 type Photo struct {
-	ID        uint64         `json:"id" gorm:"primaryKey"`
-	Title     string         `json:"title"`
-	Caption   string         `json:"caption"`
-	PhotoURL  string         `json:"photo_url"`
-	UserID    uint64         `json:"user_id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"column:deleted_at"`
-	Comments  []Comment      `json:"comments,omitempty"`
-	User      struct {
+	ID            uint64            `json:"id" gorm:"primaryKey"`
+	Title         string            `json:"title"`
+	Caption       string            `json:"caption"`
+	Entities      []entity.Entity   `json:"entities" gorm:"-"`
+	PhotoURL      string            `json:"photo_url"`
+	UserID        uint64            `json:"user_id"`
+	VariantStatus string            `json:"variant_status,omitempty"`
+	Variants      map[string]string `json:"variants,omitempty" gorm:"-"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt    `json:"-" gorm:"column:deleted_at"`
+	PhotoFile
+	LikeSummary
+	Comments []Comment `json:"comments,omitempty"`
+	User     struct {
 		ID       uint64 `json:"-"`
 		Username string `json:"username"`
 		Email    string `json:"email"`
 	} `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
-type CreatePhoto struct {
-	ID        uint64    `json:"id" `
-	Title     string    `json:"title" validate:"required"`
-	PhotoURL  string    `json:"photo_url" validate:"required"`
-	Caption   string    `json:"caption" `
-	UserID    uint64    `json:"user_id"`
+// FeedPhoto is a photo as shown in a user's home feed.
+type FeedPhoto struct {
+	ID            uint64            `json:"id"`
+	Title         string            `json:"title"`
+	Caption       string            `json:"caption"`
+	Entities      []entity.Entity   `json:"entities" gorm:"-"`
+	PhotoURL      string            `json:"photo_url"`
+	UserID        uint64            `json:"user_id"`
+	VariantStatus string            `json:"variant_status,omitempty"`
+	Variants      map[string]string `json:"variants,omitempty" gorm:"-"`
+	CommentCount  int64             `json:"comment_count"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	LikeSummary
+	Author struct {
+		ID       uint64 `json:"id"`
+		Username string `json:"username"`
+	} `json:"author" gorm:"embedded;embeddedPrefix:author_"`
+}
+
+// PhotoFile describes the stored image of an uploaded photo. Photos created
+// from a bare photo_url leave it empty.
+type PhotoFile struct {
+	StorageKey  string `json:"-"`
+	ContentType string `json:"-"`
+	Size        int64  `json:"-"`
+	Checksum    string `json:"-"`
+}
+
+// PhotoImageURL is the route serving the stored image of photo id.
+func PhotoImageURL(id uint64) string {
+	return fmt.Sprintf("/photos/%d/image", id)
+}
+
+// PhotoVariant is a resized rendition of an uploaded photo.
+type PhotoVariant struct {
+	ID        uint64    `json:"-" gorm:"primaryKey"`
+	PhotoID   uint64    `json:"photo_id"`
+	Name      string    `json:"name"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
 	CreatedAt time.Time `json:"created_at"`
+	PhotoFile
+}
+
+type CreatePhoto struct {
+	ID            uint64          `json:"id" form:"-"`
+	Title         string          `json:"title" form:"title" validate:"required"`
+	PhotoURL      string          `json:"photo_url" form:"-" validate:"required"`
+	Caption       string          `json:"caption" form:"caption"`
+	Entities      []entity.Entity `json:"entities" gorm:"-" form:"-"`
+	UserID        uint64          `json:"user_id" form:"-"`
+	VariantStatus string          `json:"variant_status,omitempty" form:"-"`
+	CreatedAt     time.Time       `json:"created_at" form:"-"`
+	PhotoFile
 }
 type GetPhoto struct {
-	ID        uint64    `json:"id" `
-	Title     string    `json:"title" binding:"required"`
-	PhotoURL  string    `json:"photo_url" binding:"required"`
-	Caption   string    `json:"caption" `
-	UserID    uint64    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint64            `json:"id" `
+	Title         string            `json:"title" binding:"required"`
+	PhotoURL      string            `json:"photo_url" binding:"required"`
+	Caption       string            `json:"caption" `
+	Entities      []entity.Entity   `json:"entities" gorm:"-"`
+	UserID        uint64            `json:"user_id"`
+	VariantStatus string            `json:"variant_status,omitempty"`
+	Variants      map[string]string `json:"variants,omitempty" gorm:"-"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	LikeSummary
 }
 
 type UpdatePhoto struct {
-	ID        uint64    `json:"id" `
-	Title     string    `json:"title" binding:"required"`
-	PhotoURL  string    `json:"photo_url" binding:"required"`
-	Caption   string    `json:"caption" binding:"required"`
-	UserID    uint64    `json:"user_id"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint64            `json:"id" `
+	Title         string            `json:"title" binding:"required"`
+	PhotoURL      string            `json:"photo_url" binding:"required"`
+	Caption       string            `json:"caption" binding:"required"`
+	Entities      []entity.Entity   `json:"entities" gorm:"-"`
+	UserID        uint64            `json:"user_id"`
+	VariantStatus string            `json:"variant_status,omitempty"`
+	Variants      map[string]string `json:"variants,omitempty" gorm:"-"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	PhotoFile
+	LikeSummary
 }
 
 func (u CreatePhoto) Validate() error {
 	if u.Title == "" && u.PhotoURL == "" {
-		return errors.New("title and photo url cannot be empty")
+		return apperror.Validation(apperror.CODE_VALIDATION, "title and photo url cannot be empty",
+			apperror.FieldError{Field: "title", Message: "title cannot be empty"},
+			apperror.FieldError{Field: "photo_url", Message: "photo url cannot be empty"},
+		)
 	}
 	if u.Title == "" {
-		return errors.New("title cannot be empty")
+		return apperror.Field("title", "title cannot be empty")
 	}
 	if u.PhotoURL == "" {
-		return errors.New("photo url cannot be empty")
+		return apperror.Field("photo_url", "photo url cannot be empty")
+	}
+	return nil
+}
+
+func (u CreatePhoto) ValidateUpload() error {
+	if u.Title == "" {
+		return apperror.Field("title", "title cannot be empty")
 	}
 	return nil
 }
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/geedotrar/mygram/pkg/entity"
+)
+
+const (
+	SearchPhotos   = "photos"
+	SearchUsers    = "users"
+	SearchComments = "comments"
+)
+
+// PhotoSearchResult is a photo matching a search. Like in the other
+// results, Highlight is the matched text, HTML-escaped, with the matching
+// words wrapped in <mark></mark>.
+type PhotoSearchResult struct {
+	ID            uint64            `json:"id"`
+	Title         string            `json:"title"`
+	Caption       string            `json:"caption"`
+	Entities      []entity.Entity   `json:"entities" gorm:"-"`
+	PhotoURL      string            `json:"photo_url"`
+	UserID        uint64            `json:"user_id"`
+	VariantStatus string            `json:"variant_status,omitempty"`
+	Variants      map[string]string `json:"variants,omitempty" gorm:"-"`
+	Highlight     string            `json:"highlight"`
+	Rank          float64           `json:"-"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	LikeSummary
+}
+
+type UserSearchResult struct {
+	ID        uint64  `json:"id"`
+	Username  string  `json:"username"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"-"`
+}
+
+type CommentSearchResult struct {
+	ID        uint64          `json:"id"`
+	Message   string          `json:"message"`
+	Entities  []entity.Entity `json:"entities" gorm:"-"`
+	PhotoID   uint64          `json:"photo_id"`
+	ParentID  *uint64         `json:"parent_id"`
+	UserID    uint64          `json:"user_id"`
+	Username  string          `json:"username"`
+	Highlight string          `json:"highlight"`
+	Rank      float64         `json:"-"`
+	CreatedAt time.Time       `json:"created_at"`
+	LikeSummary
+}
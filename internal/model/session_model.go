@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type Session struct {
+	ID         uint64     `json:"id" gorm:"primaryKey"`
+	UserID     uint64     `json:"user_id"`
+	AccessJti  string     `json:"-"`
+	RefreshJti string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type UserToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// Target types of rows, such as likes or mentions, that may point at either
+// a photo or a comment.
+const (
+	TargetPhoto   = "photo"
+	TargetComment = "comment"
+)
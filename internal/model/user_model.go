@@ -1,19 +1,25 @@
 package model
 
 import (
-	"errors"
-
 	"time"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/pkg/helper"
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID           uint64         `json:"id" gorm:"primaryKey"`
 	Username     string         `json:"username"`
 	Email        string         `json:"email"`
 	Password     string         `json:"-"`
+	Role         string         `json:"role"`
 	Dob          time.Time      `json:"dob" gorm:"column:dob"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -44,14 +50,23 @@ type UserView struct {
 	Password string    `json:"-" binding:"required"`
 	Email    string    `json:"email" binding:"required"`
 	Dob      time.Time `json:"dob" binding:"required"`
+	Role     string    `json:"role"`
+}
+
+type UserRole struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
 }
 
 func (u UserSignUp) Validate() error {
 	if len(u.Password) < 6 {
-		return errors.New("password must be at least 6 characters")
+		return apperror.Field("password", "password must be at least 6 characters")
 	}
 	if !helper.IsValidEmail(u.Email) {
-		return errors.New("invalid email format")
+		return apperror.Field("email", "invalid email format")
 	}
 	return nil
 }
@@ -2,19 +2,23 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/infrastructure"
 	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"gorm.io/gorm"
 )
 
 type CommentQuery interface {
-	GetComments(ctx context.Context) ([]model.GetCommentByID, error)
+	GetComments(ctx context.Context, params pagination.Params) (pagination.Page[model.GetCommentByID], error)
 	GetCommentByID(ctx context.Context, id uint64) (model.GetCommentByID, error)
-	GetCommentsByPhotoID(ctx context.Context, photoID uint64) ([]model.Comment, error)
-	CreateComment(ctx context.Context, comment model.CreateComment) (model.CreateComment, error)
-	UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment) (model.UpdateComment, error)
+	GetCommentsByPhotoID(ctx context.Context, photoID uint64, params pagination.Params) (pagination.Page[model.Comment], error)
+	GetCommentReplies(ctx context.Context, parentID uint64, params pagination.Params) (pagination.Page[model.Comment], error)
+	CreateComment(ctx context.Context, comment model.CreateComment, tags []string, userIDs []uint64) (model.CreateComment, error)
+	UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment, tags []string, userIDs []uint64) (model.UpdateComment, error)
 	DeleteCommentByID(ctx context.Context, id uint64) error
 	GetCommentByID1(ctx context.Context, id uint64) (model.UpdateComment, error)
 }
@@ -33,44 +37,66 @@ func (c *commentQueryImpl) GetCommentByID(ctx context.Context, id uint64) (model
 	if err := db.
 		WithContext(ctx).
 		Table("comments").
-		Where("id = ?", id).
+		Where("id = ? AND deleted_at IS NULL", id).
 		First(&comment).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.GetCommentByID{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.GetCommentByID{}, apperror.ErrNotFound
 		}
 		return model.GetCommentByID{}, err
 	}
 	return comment, nil
 }
-func (c *commentQueryImpl) GetComments(ctx context.Context) ([]model.GetCommentByID, error) {
-	db := c.db.GetConnection()
+func (c *commentQueryImpl) GetComments(ctx context.Context, params pagination.Params) (pagination.Page[model.GetCommentByID], error) {
+	db := c.db.GetReadConnection()
 	comments := []model.GetCommentByID{}
 	if err := db.
 		WithContext(ctx).
 		Table("comments").
+		Where("deleted_at IS NULL").
+		Scopes(pagination.Scope(params, "comments")).
 		Find(&comments).Error; err != nil {
-		return nil, err
+		return pagination.Page[model.GetCommentByID]{}, err
 	}
-	return comments, nil
+	return pagination.NewPage(comments, params, func(c model.GetCommentByID) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}), nil
 }
-func (c *commentQueryImpl) GetCommentsByPhotoID(ctx context.Context, photoID uint64) ([]model.Comment, error) {
-	db := c.db.GetConnection()
+
+// GetCommentsByPhotoID lists the top-level comments of a photo.
+func (c *commentQueryImpl) GetCommentsByPhotoID(ctx context.Context, photoID uint64, params pagination.Params) (pagination.Page[model.Comment], error) {
+	return c.getThread(ctx, params, "comments.photo_id = ? AND comments.parent_id IS NULL", photoID)
+}
+
+func (c *commentQueryImpl) GetCommentReplies(ctx context.Context, parentID uint64, params pagination.Params) (pagination.Page[model.Comment], error) {
+	return c.getThread(ctx, params, "comments.parent_id = ?", parentID)
+}
+
+// getThread lists comments with their reply counts. Deleted comments are
+// kept while they still have live replies, so a thread never loses its root.
+func (c *commentQueryImpl) getThread(ctx context.Context, params pagination.Params, query string, args ...any) (pagination.Page[model.Comment], error) {
+	db := c.db.GetReadConnection()
 	comments := []model.Comment{}
 	if err := db.
 		WithContext(ctx).
+		Unscoped().
 		Table("comments").
-		Where("photo_id = ?", photoID).
+		Select("comments.*, (SELECT COUNT(*) FROM comments replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL) AS reply_count").
+		Where(query, args...).
+		Where("(comments.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL))").
+		Scopes(pagination.Scope(params, "comments")).
 		Find(&comments).Error; err != nil {
-		return nil, err
+		return pagination.Page[model.Comment]{}, err
 	}
-	return comments, nil
+	return pagination.NewPage(comments, params, func(c model.Comment) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}), nil
 }
 func (c *commentQueryImpl) DeleteCommentByID(ctx context.Context, id uint64) error {
 	db := c.db.GetConnection()
 	if err := db.
 		WithContext(ctx).
 		Table("comments").
-		Delete(&model.UpdateComment{ID: id}).Error; err != nil {
+		Delete(&model.Comment{ID: id}).Error; err != nil {
 		return err
 	}
 	return nil
@@ -81,37 +107,50 @@ func (c *commentQueryImpl) GetCommentByID1(ctx context.Context, id uint64) (mode
 	if err := db.
 		WithContext(ctx).
 		Table("comments").
-		Where("id = ?", id).
+		Where("id = ? AND deleted_at IS NULL", id).
 		First(&comment).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdateComment{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdateComment{}, apperror.ErrNotFound
 		}
 		return model.UpdateComment{}, err
 	}
 	return comment, nil
 }
-func (c *commentQueryImpl) CreateComment(ctx context.Context, comment model.CreateComment) (model.CreateComment, error) {
+
+// CreateComment stores the comment along with the hashtags and mentions of
+// its message in one transaction.
+func (c *commentQueryImpl) CreateComment(ctx context.Context, comment model.CreateComment, tags []string, userIDs []uint64) (model.CreateComment, error) {
 	db := c.db.GetConnection()
-	if err := db.
-		WithContext(ctx).
-		Table("comments").
-		Save(&comment).Error; err != nil {
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("comments").
+			Save(&comment).Error; err != nil {
+			return err
+		}
+		return replaceEntities(tx, model.TargetComment, comment.ID, tags, userIDs)
+	}); err != nil {
 		return model.CreateComment{}, err
 	}
 	return comment, nil
 }
 
-func (c *commentQueryImpl) UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment) (model.UpdateComment, error) {
+// UpdateComment updates the comment and swaps the hashtags and mentions of
+// its message in one transaction.
+func (c *commentQueryImpl) UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment, tags []string, userIDs []uint64) (model.UpdateComment, error) {
 	db := c.db.GetConnection()
 	updatedComment := model.UpdateComment{}
-	if err := db.
-		WithContext(ctx).
-		Table("comments").
-		Where("id = ?", id).
-		Updates(&comment).
-		First(&updatedComment).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdateComment{}, nil
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("comments").
+			Where("id = ?", id).
+			Updates(&comment).
+			First(&updatedComment).Error; err != nil {
+			return err
+		}
+		return replaceEntities(tx, model.TargetComment, id, tags, userIDs)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdateComment{}, apperror.ErrNotFound
 		}
 		return model.UpdateComment{}, err
 	}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+type EntityQuery interface {
+	GetMentionedUsers(ctx context.Context, targetType string, targetIDs []uint64) (map[uint64][]model.MentionedUser, error)
+	GetMentions(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.MentionView], error)
+}
+
+type entityQueryImpl struct {
+	db infrastructure.GormPostgres
+}
+
+func NewEntityQuery(db infrastructure.GormPostgres) EntityQuery {
+	return &entityQueryImpl{db: db}
+}
+
+// replaceEntities swaps the hashtags and mentions of a photo or comment for
+// the given ones within tx, the transaction that stores the target.
+func replaceEntities(tx *gorm.DB, targetType string, targetID uint64, tags []string, userIDs []uint64) error {
+	if err := tx.
+		Table("hashtags").
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Delete(&model.Hashtag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.
+		Table("mentions").
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Delete(&model.Mention{}).Error; err != nil {
+		return err
+	}
+
+	if len(tags) > 0 {
+		hashtags := make([]model.Hashtag, 0, len(tags))
+		for _, tag := range tags {
+			hashtags = append(hashtags, model.Hashtag{Tag: tag, TargetType: targetType, TargetID: targetID})
+		}
+		if err := tx.Table("hashtags").Create(&hashtags).Error; err != nil {
+			return err
+		}
+	}
+	if len(userIDs) > 0 {
+		mentions := make([]model.Mention, 0, len(userIDs))
+		for _, userID := range userIDs {
+			mentions = append(mentions, model.Mention{UserID: userID, TargetType: targetType, TargetID: targetID})
+		}
+		if err := tx.Table("mentions").Create(&mentions).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMentionedUsers loads the users mentioned by each target in one query.
+func (e *entityQueryImpl) GetMentionedUsers(ctx context.Context, targetType string, targetIDs []uint64) (map[uint64][]model.MentionedUser, error) {
+	users := map[uint64][]model.MentionedUser{}
+	if len(targetIDs) == 0 {
+		return users, nil
+	}
+
+	db := e.db.GetReadConnection()
+	rows := []model.MentionedUser{}
+	if err := db.
+		WithContext(ctx).
+		Table("mentions").
+		Select("mentions.target_id, users.id AS user_id, users.username").
+		Joins("JOIN users ON users.id = mentions.user_id AND users.deleted_at IS NULL").
+		Where("mentions.target_type = ? AND mentions.target_id IN ?", targetType, targetIDs).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		users[row.TargetID] = append(users[row.TargetID], row)
+	}
+	return users, nil
+}
+
+// GetMentions lists the photos and comments mentioning userID, newest first.
+func (e *entityQueryImpl) GetMentions(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.MentionView], error) {
+	db := e.db.GetReadConnection()
+	mentions := []model.MentionView{}
+	if err := db.
+		WithContext(ctx).
+		Table("mentions").
+		Select(`mentions.id, mentions.target_type, mentions.target_id, mentions.created_at,
+			COALESCE(photos.id, comments.photo_id) AS photo_id,
+			COALESCE(photos.user_id, comments.user_id) AS author_id,
+			COALESCE(photos.caption, comments.message) AS text`).
+		Joins("LEFT JOIN photos ON mentions.target_type = ? AND photos.id = mentions.target_id AND photos.deleted_at IS NULL", model.TargetPhoto).
+		Joins("LEFT JOIN comments ON mentions.target_type = ? AND comments.id = mentions.target_id AND comments.deleted_at IS NULL", model.TargetComment).
+		Where("mentions.user_id = ?", userID).
+		Where("(photos.id IS NOT NULL OR comments.id IS NOT NULL)").
+		Scopes(pagination.Scope(params, "mentions")).
+		Find(&mentions).Error; err != nil {
+		return pagination.Page[model.MentionView]{}, err
+	}
+	return pagination.NewPage(mentions, params, func(m model.MentionView) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: m.CreatedAt, ID: m.ID}
+	}), nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"gorm.io/gorm/clause"
+)
+
+type LikeQuery interface {
+	CreateLike(ctx context.Context, like model.Like) (bool, error)
+	DeleteLike(ctx context.Context, userID uint64, targetType string, targetID uint64) error
+	GetLikeSummaries(ctx context.Context, targetType string, targetIDs []uint64, userID uint64) (map[uint64]model.LikeSummary, error)
+	GetLikers(ctx context.Context, targetType string, targetID uint64, params pagination.Params) (pagination.Page[model.LikeUser], error)
+}
+
+type likeQueryImpl struct {
+	db infrastructure.GormPostgres
+}
+
+func NewLikeQuery(db infrastructure.GormPostgres) LikeQuery {
+	return &likeQueryImpl{db: db}
+}
+
+// CreateLike does nothing and reports false when the user already likes
+// the target.
+func (l *likeQueryImpl) CreateLike(ctx context.Context, like model.Like) (bool, error) {
+	db := l.db.GetConnection()
+	result := db.
+		WithContext(ctx).
+		Table("likes").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&like)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (l *likeQueryImpl) DeleteLike(ctx context.Context, userID uint64, targetType string, targetID uint64) error {
+	db := l.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("likes").
+		Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).
+		Delete(&model.Like{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLikeSummaries counts the likes of every target in one query. Targets
+// nobody liked are missing from the map.
+func (l *likeQueryImpl) GetLikeSummaries(ctx context.Context, targetType string, targetIDs []uint64, userID uint64) (map[uint64]model.LikeSummary, error) {
+	summaries := map[uint64]model.LikeSummary{}
+	if len(targetIDs) == 0 {
+		return summaries, nil
+	}
+
+	db := l.db.GetReadConnection()
+	rows := []struct {
+		TargetID  uint64
+		LikeCount int64
+		LikedByMe bool
+	}{}
+	if err := db.
+		WithContext(ctx).
+		Table("likes").
+		Select("target_id, COUNT(*) AS like_count, BOOL_OR(user_id = ?) AS liked_by_me", userID).
+		Where("target_type = ? AND target_id IN ?", targetType, targetIDs).
+		Group("target_id").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		summaries[row.TargetID] = model.LikeSummary{LikeCount: row.LikeCount, LikedByMe: row.LikedByMe}
+	}
+	return summaries, nil
+}
+
+func (l *likeQueryImpl) GetLikers(ctx context.Context, targetType string, targetID uint64, params pagination.Params) (pagination.Page[model.LikeUser], error) {
+	db := l.db.GetReadConnection()
+	users := []model.LikeUser{}
+	if err := db.
+		WithContext(ctx).
+		Table("likes").
+		Select("likes.id AS like_id, likes.created_at AS liked_at, users.id, users.username").
+		Joins("JOIN users ON users.id = likes.user_id AND users.deleted_at IS NULL").
+		Where("likes.target_type = ? AND likes.target_id = ?", targetType, targetID).
+		Scopes(pagination.Scope(params, "likes")).
+		Find(&users).Error; err != nil {
+		return pagination.Page[model.LikeUser]{}, err
+	}
+	return pagination.NewPage(users, params, func(u model.LikeUser) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: u.LikedAt, ID: u.LikeID}
+	}), nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// mockPostgres serves both connections from one sqlmock backed gorm.DB.
+type mockPostgres struct {
+	db *gorm.DB
+}
+
+func (m mockPostgres) GetConnection() *gorm.DB        { return m.db }
+func (m mockPostgres) GetReadConnection() *gorm.DB    { return m.db }
+func (m mockPostgres) Ping(ctx context.Context) error { return nil }
+func (m mockPostgres) Close() error                   { return nil }
+
+func newMockPostgres(t *testing.T) (mockPostgres, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return mockPostgres{db: db}, mock
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+type NotificationQuery interface {
+	CreateNotification(ctx context.Context, notification model.Notification) (model.Notification, error)
+	GetNotifications(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.NotificationView], error)
+	GetNotificationByID(ctx context.Context, id uint64) (model.NotificationView, error)
+	CountUnread(ctx context.Context, userID uint64) (int64, error)
+	MarkRead(ctx context.Context, userID uint64, ids []uint64) error
+}
+
+type notificationQueryImpl struct {
+	db infrastructure.GormPostgres
+}
+
+func NewNotificationQuery(db infrastructure.GormPostgres) NotificationQuery {
+	return &notificationQueryImpl{db: db}
+}
+
+func (n *notificationQueryImpl) CreateNotification(ctx context.Context, notification model.Notification) (model.Notification, error) {
+	db := n.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("notifications").
+		Create(&notification).Error; err != nil {
+		return model.Notification{}, err
+	}
+	return notification, nil
+}
+
+func (n *notificationQueryImpl) GetNotifications(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.NotificationView], error) {
+	db := n.db.GetConnection()
+	notifications := []model.NotificationView{}
+	if err := db.
+		WithContext(ctx).
+		Table("notifications").
+		Select("notifications.*, users.username AS actor_username").
+		Joins("LEFT JOIN users ON users.id = notifications.actor_id").
+		Where("notifications.user_id = ?", userID).
+		Scopes(pagination.Scope(params, "notifications")).
+		Find(&notifications).Error; err != nil {
+		return pagination.Page[model.NotificationView]{}, err
+	}
+	return pagination.NewPage(notifications, params, func(n model.NotificationView) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	}), nil
+}
+
+func (n *notificationQueryImpl) GetNotificationByID(ctx context.Context, id uint64) (model.NotificationView, error) {
+	db := n.db.GetConnection()
+	notification := model.NotificationView{}
+	if err := db.
+		WithContext(ctx).
+		Table("notifications").
+		Select("notifications.*, users.username AS actor_username").
+		Joins("LEFT JOIN users ON users.id = notifications.actor_id").
+		Where("notifications.id = ?", id).
+		Take(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.NotificationView{}, apperror.ErrNotFound
+		}
+		return model.NotificationView{}, err
+	}
+	return notification, nil
+}
+
+func (n *notificationQueryImpl) CountUnread(ctx context.Context, userID uint64) (int64, error) {
+	db := n.db.GetConnection()
+	var count int64
+	if err := db.
+		WithContext(ctx).
+		Table("notifications").
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkRead marks the given notifications of userID read, or all of them
+// when ids is empty. IDs of other users' notifications are ignored.
+func (n *notificationQueryImpl) MarkRead(ctx context.Context, userID uint64, ids []uint64) error {
+	db := n.db.GetConnection()
+	query := db.
+		WithContext(ctx).
+		Table("notifications").
+		Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if err := query.Update("read_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,20 +2,31 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/infrastructure"
 	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"gorm.io/gorm"
 )
 
 type PhotoQuery interface {
-	GetPhotos(ctx context.Context) ([]model.Photo, error)
+	GetPhotos(ctx context.Context, params pagination.Params) (pagination.Page[model.Photo], error)
+	GetFeed(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.FeedPhoto], error)
+	GetPhotosByTag(ctx context.Context, tag string, params pagination.Params) (pagination.Page[model.GetPhoto], error)
 	GetPhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error)
-	GetPhotoByUserID(ctx context.Context, photoID uint64) ([]model.GetPhoto, error)
-	CreatePhoto(ctx context.Context, photo model.CreatePhoto) (model.CreatePhoto, error)
-	UpdatePhoto(ctx context.Context, id uint64, user model.UpdatePhoto) (model.UpdatePhoto, error)
+	GetPhotosByIDs(ctx context.Context, ids []uint64) ([]model.UpdatePhoto, error)
+	GetPhotoByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error)
+	CreatePhoto(ctx context.Context, photo model.CreatePhoto, tags []string, userIDs []uint64) (model.CreatePhoto, error)
+	UpdatePhoto(ctx context.Context, id uint64, photo model.UpdatePhoto, tags []string, userIDs []uint64) (model.UpdatePhoto, error)
 	DeletePhotoByID(ctx context.Context, id uint64) error
+
+	GetPendingPhotoIDs(ctx context.Context) ([]uint64, error)
+	UpdateVariantStatus(ctx context.Context, id uint64, status string) error
+	GetPhotoVariants(ctx context.Context, photoID uint64) ([]model.PhotoVariant, error)
+	SavePhotoVariants(ctx context.Context, photoID uint64, variants []model.PhotoVariant) error
 }
 
 type photoQueryImpl struct {
@@ -26,16 +37,61 @@ func NewPhotoQuery(db infrastructure.GormPostgres) PhotoQuery {
 	return &photoQueryImpl{db: db}
 }
 
-func (p *photoQueryImpl) GetPhotos(ctx context.Context) ([]model.Photo, error) {
-	db := p.db.GetConnection()
+func (p *photoQueryImpl) GetPhotos(ctx context.Context, params pagination.Params) (pagination.Page[model.Photo], error) {
+	db := p.db.GetReadConnection()
 	photos := []model.Photo{}
 	if err := db.
 		WithContext(ctx).
 		Table("photos").
+		Scopes(pagination.Scope(params, "photos")).
 		Find(&photos).Error; err != nil {
-		return []model.Photo{}, err
+		return pagination.Page[model.Photo]{}, err
 	}
-	return photos, nil
+	return pagination.NewPage(photos, params, func(p model.Photo) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	}), nil
+}
+
+// GetFeed lists the photos of userID and everyone they follow, newest
+// first, with the author and comment count joined in.
+func (p *photoQueryImpl) GetFeed(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.FeedPhoto], error) {
+	db := p.db.GetReadConnection()
+	photos := []model.FeedPhoto{}
+	if err := db.
+		WithContext(ctx).
+		Table("photos").
+		Select(`photos.id, photos.title, photos.caption, photos.photo_url, photos.user_id,
+			photos.variant_status, photos.created_at, photos.updated_at,
+			users.id AS author_id, users.username AS author_username,
+			(SELECT COUNT(*) FROM comments WHERE comments.photo_id = photos.id AND comments.deleted_at IS NULL) AS comment_count`).
+		Joins("JOIN users ON users.id = photos.user_id AND users.deleted_at IS NULL").
+		Where("photos.deleted_at IS NULL").
+		Where("photos.user_id = ? OR photos.user_id IN (SELECT following_id FROM follows WHERE follower_id = ?)", userID, userID).
+		Scopes(pagination.Scope(params, "photos")).
+		Find(&photos).Error; err != nil {
+		return pagination.Page[model.FeedPhoto]{}, err
+	}
+	return pagination.NewPage(photos, params, func(p model.FeedPhoto) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	}), nil
+}
+
+func (p *photoQueryImpl) GetPhotosByTag(ctx context.Context, tag string, params pagination.Params) (pagination.Page[model.GetPhoto], error) {
+	db := p.db.GetReadConnection()
+	photos := []model.GetPhoto{}
+	if err := db.
+		WithContext(ctx).
+		Table("photos").
+		Select("photos.*").
+		Joins("JOIN hashtags ON hashtags.target_type = ? AND hashtags.target_id = photos.id", model.TargetPhoto).
+		Where("hashtags.tag = ? AND photos.deleted_at IS NULL", tag).
+		Scopes(pagination.Scope(params, "photos")).
+		Find(&photos).Error; err != nil {
+		return pagination.Page[model.GetPhoto]{}, err
+	}
+	return pagination.NewPage(photos, params, func(p model.GetPhoto) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	}), nil
 }
 
 func (p *photoQueryImpl) GetPhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error) {
@@ -45,49 +101,94 @@ func (p *photoQueryImpl) GetPhotoByID(ctx context.Context, id uint64) (model.Upd
 		WithContext(ctx).
 		Table("photos").
 		Where("id = ?", id).
-		Find(&photo).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdatePhoto{}, nil
+		Take(&photo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdatePhoto{}, apperror.ErrNotFound
 		}
 		return model.UpdatePhoto{}, err
 	}
 	return photo, nil
 }
 
-func (p *photoQueryImpl) GetPhotoByUserID(ctx context.Context, userID uint64) ([]model.GetPhoto, error) {
+// GetPhotosByIDs loads many photos at once. Unknown IDs are left out.
+func (p *photoQueryImpl) GetPhotosByIDs(ctx context.Context, ids []uint64) ([]model.UpdatePhoto, error) {
+	photos := []model.UpdatePhoto{}
+	if len(ids) == 0 {
+		return photos, nil
+	}
 	db := p.db.GetConnection()
-	photos := []model.GetPhoto{}
 	if err := db.
 		WithContext(ctx).
 		Table("photos").
-		Where("user_id = ?", userID).
+		Where("id IN ?", ids).
 		Find(&photos).Error; err != nil {
-		return []model.GetPhoto{}, err
+		return nil, err
 	}
 	return photos, nil
 }
 
-func (p *photoQueryImpl) CreatePhoto(ctx context.Context, photo model.CreatePhoto) (model.CreatePhoto, error) {
-	db := p.db.GetConnection()
+func (p *photoQueryImpl) GetPhotoByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error) {
+	db := p.db.GetReadConnection()
+	photos := []model.GetPhoto{}
 	if err := db.
 		WithContext(ctx).
 		Table("photos").
-		Save(&photo).Error; err != nil {
+		Where("user_id = ?", userID).
+		Scopes(pagination.Scope(params, "photos")).
+		Find(&photos).Error; err != nil {
+		return pagination.Page[model.GetPhoto]{}, err
+	}
+	return pagination.NewPage(photos, params, func(p model.GetPhoto) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	}), nil
+}
+
+// CreatePhoto stores the photo along with the hashtags and mentions of its
+// caption in one transaction. An uploaded photo gets the URL of its image
+// route, which needs the new ID, in the same transaction.
+func (p *photoQueryImpl) CreatePhoto(ctx context.Context, photo model.CreatePhoto, tags []string, userIDs []uint64) (model.CreatePhoto, error) {
+	db := p.db.GetConnection()
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("photos").
+			Save(&photo).Error; err != nil {
+			return err
+		}
+		if photo.StorageKey != "" {
+			photo.PhotoURL = model.PhotoImageURL(photo.ID)
+			if err := tx.
+				Table("photos").
+				Where("id = ?", photo.ID).
+				Update("photo_url", photo.PhotoURL).Error; err != nil {
+				return err
+			}
+		}
+		return replaceEntities(tx, model.TargetPhoto, photo.ID, tags, userIDs)
+	}); err != nil {
 		return model.CreatePhoto{}, err
 	}
 	return photo, nil
 }
 
-func (u *photoQueryImpl) UpdatePhoto(ctx context.Context, id uint64, user model.UpdatePhoto) (model.UpdatePhoto, error) {
-	db := u.db.GetConnection()
+// UpdatePhoto updates the photo and swaps the hashtags and mentions of its
+// caption in one transaction.
+func (p *photoQueryImpl) UpdatePhoto(ctx context.Context, id uint64, photo model.UpdatePhoto, tags []string, userIDs []uint64) (model.UpdatePhoto, error) {
+	db := p.db.GetConnection()
 	updatedPhoto := model.UpdatePhoto{}
-	if err := db.
-		WithContext(ctx).
-		Table("photos").
-		Where("id = ?", id).Updates(&user).First(&updatedPhoto).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdatePhoto{}, nil
+	if err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("photos").
+			Where("id = ?", id).
+			Updates(&photo).
+			First(&updatedPhoto).Error; err != nil {
+			return err
 		}
+		return replaceEntities(tx, model.TargetPhoto, id, tags, userIDs)
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdatePhoto{}, apperror.ErrNotFound
+		}
+		return model.UpdatePhoto{}, err
 	}
 	return updatedPhoto, nil
 }
@@ -102,3 +203,67 @@ func (p *photoQueryImpl) DeletePhotoByID(ctx context.Context, id uint64) error {
 	}
 	return nil
 }
+
+func (p *photoQueryImpl) GetPendingPhotoIDs(ctx context.Context) ([]uint64, error) {
+	db := p.db.GetConnection()
+	ids := []uint64{}
+	if err := db.
+		WithContext(ctx).
+		Table("photos").
+		Where("variant_status = ? AND deleted_at IS NULL", model.VariantStatusPending).
+		Order("id").
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (p *photoQueryImpl) UpdateVariantStatus(ctx context.Context, id uint64, status string) error {
+	db := p.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("photos").
+		Where("id = ?", id).
+		Update("variant_status", status).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *photoQueryImpl) GetPhotoVariants(ctx context.Context, photoID uint64) ([]model.PhotoVariant, error) {
+	db := p.db.GetConnection()
+	variants := []model.PhotoVariant{}
+	if err := db.
+		WithContext(ctx).
+		Table("photo_variants").
+		Where("photo_id = ?", photoID).
+		Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// SavePhotoVariants replaces the variants of a photo and marks it ready in
+// one transaction.
+func (p *photoQueryImpl) SavePhotoVariants(ctx context.Context, photoID uint64, variants []model.PhotoVariant) error {
+	db := p.db.GetConnection()
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Table("photo_variants").
+			Where("photo_id = ?", photoID).
+			Delete(&model.PhotoVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variants) > 0 {
+			if err := tx.
+				Table("photo_variants").
+				Create(&variants).Error; err != nil {
+				return err
+			}
+		}
+		return tx.
+			Table("photos").
+			Where("id = ?", photoID).
+			Update("variant_status", model.VariantStatusReady).Error
+	})
+}
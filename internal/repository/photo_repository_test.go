@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/model"
+)
+
+func TestCreatePhotoLinksEntities(t *testing.T) {
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WithArgs(model.TargetPhoto, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WithArgs(model.TargetPhoto, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "hashtags"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "mentions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	photo, err := NewPhotoQuery(db).CreatePhoto(context.Background(), model.CreatePhoto{Title: "t", Caption: "#go @bob"}, []string{"go"}, []uint64{2})
+	if err != nil {
+		t.Fatalf("CreatePhoto: %v", err)
+	}
+	if photo.ID != 7 {
+		t.Errorf("got photo %d, want 7", photo.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreatePhotoSetsUploadURL(t *testing.T) {
+	errUpdate := errors.New("connection reset")
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`UPDATE "photos" SET "photo_url"=\$1`).WithArgs("/photos/7/image", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(`UPDATE "photos" SET "photo_url"=\$1`).WillReturnError(errUpdate)
+	mock.ExpectRollback()
+
+	upload := model.CreatePhoto{Title: "t", PhotoFile: model.PhotoFile{StorageKey: "photos/1/a.jpg"}}
+	photo, err := NewPhotoQuery(db).CreatePhoto(context.Background(), upload, nil, nil)
+	if err != nil {
+		t.Fatalf("CreatePhoto: %v", err)
+	}
+	if photo.PhotoURL != "/photos/7/image" {
+		t.Errorf("got photo_url %q, want /photos/7/image", photo.PhotoURL)
+	}
+
+	if _, err := NewPhotoQuery(db).CreatePhoto(context.Background(), upload, nil, nil); !errors.Is(err, errUpdate) {
+		t.Fatalf("got error %v, want %v", err, errUpdate)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreatePhotoRollsBackOnLinkFailure(t *testing.T) {
+	errLink := errors.New("value too long for type character varying(100)")
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "hashtags"`).WillReturnError(errLink)
+	mock.ExpectRollback()
+
+	_, err := NewPhotoQuery(db).CreatePhoto(context.Background(), model.CreatePhoto{Title: "t"}, []string{"go"}, nil)
+	if !errors.Is(err, errLink) {
+		t.Fatalf("got error %v, want %v", err, errLink)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateCommentRollsBackOnLinkFailure(t *testing.T) {
+	errLink := errors.New("mentions_user_id_fkey")
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "comments"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WithArgs(model.TargetComment, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WithArgs(model.TargetComment, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "mentions"`).WillReturnError(errLink)
+	mock.ExpectRollback()
+
+	_, err := NewCommentQuery(db).CreateComment(context.Background(), model.CreateComment{Message: "@bob", PhotoID: 1, UserID: 1}, nil, []uint64{2})
+	if !errors.Is(err, errLink) {
+		t.Fatalf("got error %v, want %v", err, errLink)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdatePhotoRollsBackOnLinkFailure(t *testing.T) {
+	errLink := errors.New("mentions_user_id_fkey")
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "photos"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id", "caption"}).AddRow(7, "@bob"))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WithArgs(model.TargetPhoto, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WithArgs(model.TargetPhoto, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "mentions"`).WillReturnError(errLink)
+	mock.ExpectRollback()
+
+	_, err := NewPhotoQuery(db).UpdatePhoto(context.Background(), 7, model.UpdatePhoto{Title: "t", Caption: "@bob"}, nil, []uint64{2})
+	if !errors.Is(err, errLink) {
+		t.Fatalf("got error %v, want %v", err, errLink)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdatePhotoNotFound(t *testing.T) {
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "photos"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "photos"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err := NewPhotoQuery(db).UpdatePhoto(context.Background(), 7, model.UpdatePhoto{Caption: "#go"}, []string{"go"}, nil)
+	if !errors.Is(err, apperror.ErrNotFound) {
+		t.Fatalf("got error %v, want apperror.ErrNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateCommentLinksEntities(t *testing.T) {
+	db, mock := newMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "comments"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "comments"`).WillReturnRows(sqlmock.NewRows([]string{"id", "message"}).AddRow(3, "#go"))
+	mock.ExpectExec(`DELETE FROM "hashtags"`).WithArgs(model.TargetComment, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "mentions"`).WithArgs(model.TargetComment, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "hashtags"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	comment, err := NewCommentQuery(db).UpdateComment(context.Background(), 3, model.UpdateComment{Message: "#go"}, []string{"go"}, nil)
+	if err != nil {
+		t.Fatalf("UpdateComment: %v", err)
+	}
+	if comment.ID != 3 || comment.Message != "#go" {
+		t.Errorf("got comment %+v", comment)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
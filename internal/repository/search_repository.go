@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+// ts_headline copies the text as is, so the matching words are delimited
+// with private use characters, stripped from the text beforehand, and only
+// turned into <mark></mark> once the rest has been HTML-escaped.
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+	// headlineOptions keeps highlights short enough for a result list.
+	headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MinWords=10, MaxWords=30, MaxFragments=2"
+)
+
+var headlineMarks = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>")
+
+// markHighlight HTML-escapes a ts_headline result and wraps its matches in
+// <mark></mark>.
+func markHighlight(highlight string) string {
+	return headlineMarks.Replace(html.EscapeString(highlight))
+}
+
+type SearchQuery interface {
+	SearchPhotos(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.PhotoSearchResult], error)
+	SearchUsers(ctx context.Context, query string, username string, params pagination.Params) (pagination.Page[model.UserSearchResult], error)
+	SearchComments(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.CommentSearchResult], error)
+}
+
+type searchQueryImpl struct {
+	db infrastructure.GormPostgres
+}
+
+func NewSearchQuery(db infrastructure.GormPostgres) SearchQuery {
+	return &searchQueryImpl{db: db}
+}
+
+// SearchPhotos matches query, in web search syntax, against the titles and
+// captions. Highlights are only computed for the rows of the page.
+func (s *searchQueryImpl) SearchPhotos(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.PhotoSearchResult], error) {
+	db := s.db.GetReadConnection()
+	matches := db.
+		Table("photos").
+		Select("photos.*, ts_rank(photos.search_vector, websearch_to_tsquery('english', ?)) AS rank", query).
+		Where("photos.search_vector @@ websearch_to_tsquery('english', ?) AND photos.deleted_at IS NULL", query)
+	photos := []model.PhotoSearchResult{}
+	if err := db.
+		WithContext(ctx).
+		Table("(?) AS results", matches).
+		Select("results.*, ts_headline('english', translate(results.title || ' ' || COALESCE(results.caption, ''), ?, ''), websearch_to_tsquery('english', ?), ?) AS highlight", headlineStart+headlineStop, query, headlineOptions).
+		Scopes(pagination.RankScope(params, "results.rank", "results.id")).
+		Find(&photos).Error; err != nil {
+		return pagination.Page[model.PhotoSearchResult]{}, err
+	}
+	for i := range photos {
+		photos[i].Highlight = markHighlight(photos[i].Highlight)
+	}
+	return pagination.NewPage(photos, params, func(p model.PhotoSearchResult) pagination.Cursor {
+		return pagination.Cursor{Rank: p.Rank, ID: p.ID}
+	}), nil
+}
+
+// SearchUsers matches query, a prefix tsquery, against the usernames,
+// ranking the user whose whole username is username first.
+func (s *searchQueryImpl) SearchUsers(ctx context.Context, query string, username string, params pagination.Params) (pagination.Page[model.UserSearchResult], error) {
+	db := s.db.GetReadConnection()
+	matches := db.
+		Table("users").
+		Select("users.id, users.username, ts_rank(users.search_vector, to_tsquery('simple', ?)) + CASE WHEN LOWER(users.username) = LOWER(?) THEN 1 ELSE 0 END AS rank", query, username).
+		Where("users.search_vector @@ to_tsquery('simple', ?) AND users.deleted_at IS NULL", query)
+	users := []model.UserSearchResult{}
+	if err := db.
+		WithContext(ctx).
+		Table("(?) AS results", matches).
+		Select("results.*, ts_headline('simple', translate(results.username, ?, ''), to_tsquery('simple', ?), ?) AS highlight", headlineStart+headlineStop, query, headlineOptions).
+		Scopes(pagination.RankScope(params, "results.rank", "results.id")).
+		Find(&users).Error; err != nil {
+		return pagination.Page[model.UserSearchResult]{}, err
+	}
+	for i := range users {
+		users[i].Highlight = markHighlight(users[i].Highlight)
+	}
+	return pagination.NewPage(users, params, func(u model.UserSearchResult) pagination.Cursor {
+		return pagination.Cursor{Rank: u.Rank, ID: u.ID}
+	}), nil
+}
+
+// SearchComments matches query, in web search syntax, against the comment
+// messages, along with their authors.
+func (s *searchQueryImpl) SearchComments(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.CommentSearchResult], error) {
+	db := s.db.GetReadConnection()
+	matches := db.
+		Table("comments").
+		Select("comments.id, comments.message, comments.photo_id, comments.parent_id, comments.user_id, comments.created_at, users.username, ts_rank(comments.search_vector, websearch_to_tsquery('english', ?)) AS rank", query).
+		Joins("JOIN users ON users.id = comments.user_id AND users.deleted_at IS NULL").
+		Where("comments.search_vector @@ websearch_to_tsquery('english', ?) AND comments.deleted_at IS NULL", query)
+	comments := []model.CommentSearchResult{}
+	if err := db.
+		WithContext(ctx).
+		Table("(?) AS results", matches).
+		Select("results.*, ts_headline('english', translate(results.message, ?, ''), websearch_to_tsquery('english', ?), ?) AS highlight", headlineStart+headlineStop, query, headlineOptions).
+		Scopes(pagination.RankScope(params, "results.rank", "results.id")).
+		Find(&comments).Error; err != nil {
+		return pagination.Page[model.CommentSearchResult]{}, err
+	}
+	for i := range comments {
+		comments[i].Highlight = markHighlight(comments[i].Highlight)
+	}
+	return pagination.NewPage(comments, params, func(c model.CommentSearchResult) pagination.Cursor {
+		return pagination.Cursor{Rank: c.Rank, ID: c.ID}
+	}), nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+// mark delimits a match the way ts_headline does.
+func mark(s string) string {
+	return headlineStart + s + headlineStop
+}
+
+func TestMarkHighlight(t *testing.T) {
+	tests := []struct {
+		highlight string
+		want      string
+	}{
+		{"a cat on a mat", "a <mark>cat</mark> on a mat"},
+		{`<img src=x onerror="alert(1)"> cat`, `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>cat</mark>`},
+		{"<script>", "<mark>&lt;script&gt;</mark>"},
+		{"Tom & Jerry's <mark>", "Tom &amp; Jerry&#39;s &lt;mark&gt;"},
+	}
+	for _, tt := range tests {
+		if got := markHighlight(tt.highlight); got != tt.want {
+			t.Errorf("markHighlight(%q) = %q, want %q", tt.highlight, got, tt.want)
+		}
+	}
+}
+
+func TestSearchCommentsEscapesHighlight(t *testing.T) {
+	db, mock := newMockPostgres(t)
+	// the delimiters are stripped from the message before ts_headline runs
+	mock.ExpectQuery(`ts_headline\('english', translate\(results.message, \$\d+, ''\)`).
+		WithArgs(headlineStart+headlineStop, "cat", headlineOptions, "cat", "cat", 21).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message", "highlight"}).
+			AddRow(1, "<script>alert(1)</script> cat", "<script>alert(1)</script> cat"))
+
+	page, err := NewSearchQuery(db).SearchComments(context.Background(), "cat", pagination.Params{Limit: 20})
+	if err != nil {
+		t.Fatalf("SearchComments: %v (%v)", err, mock.ExpectationsWereMet())
+	}
+	if len(page.Data) != 1 {
+		t.Fatalf("got %d results, want 1", len(page.Data))
+	}
+	if got, want := page.Data[0].Highlight, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>cat</mark>"; got != want {
+		t.Errorf("highlight %q, want %q", got, want)
+	}
+}
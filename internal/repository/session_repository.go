@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/infrastructure"
+	"github.com/geedotrar/mygram/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type SessionQuery interface {
+	CreateSession(ctx context.Context, session model.Session) (model.Session, error)
+	GetSessionByID(ctx context.Context, id uint64) (model.Session, error)
+	RotateSession(ctx context.Context, session model.Session, oldRefreshJti string) (bool, error)
+	RevokeSession(ctx context.Context, id uint64) error
+	RevokeSessionsByUserID(ctx context.Context, userID uint64) error
+}
+
+type sessionQueryImpl struct {
+	db infrastructure.GormPostgres
+}
+
+func NewSessionQuery(db infrastructure.GormPostgres) SessionQuery {
+	return &sessionQueryImpl{db: db}
+}
+
+func (s *sessionQueryImpl) CreateSession(ctx context.Context, session model.Session) (model.Session, error) {
+	db := s.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("sessions").
+		Create(&session).Error; err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+func (s *sessionQueryImpl) GetSessionByID(ctx context.Context, id uint64) (model.Session, error) {
+	db := s.db.GetConnection()
+	session := model.Session{}
+	if err := db.
+		WithContext(ctx).
+		Table("sessions").
+		Where("id = ?", id).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Session{}, apperror.ErrNotFound
+		}
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+// RotateSession swaps the token IDs of a live session only if its refresh
+// token is still oldRefreshJti, so two concurrent refreshes cannot both win.
+func (s *sessionQueryImpl) RotateSession(ctx context.Context, session model.Session, oldRefreshJti string) (bool, error) {
+	db := s.db.GetConnection()
+	result := db.
+		WithContext(ctx).
+		Table("sessions").
+		Where("id = ? AND refresh_jti = ? AND revoked_at IS NULL", session.ID, oldRefreshJti).
+		Updates(map[string]any{
+			"access_jti":  session.AccessJti,
+			"refresh_jti": session.RefreshJti,
+			"expires_at":  session.ExpiresAt,
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *sessionQueryImpl) RevokeSession(ctx context.Context, id uint64) error {
+	db := s.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("sessions").
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sessionQueryImpl) RevokeSessionsByUserID(ctx context.Context, userID uint64) error {
+	db := s.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("sessions").
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return nil
+}
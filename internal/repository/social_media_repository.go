@@ -2,17 +2,20 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/infrastructure"
 	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
 
 	"gorm.io/gorm"
 )
 
 type SocialMediaQuery interface {
-	GetSocialMedias(ctx context.Context) ([]model.SocialMedia, error)
+	GetSocialMedias(ctx context.Context, params pagination.Params) (pagination.Page[model.SocialMedia], error)
 	GetSocialMediaByID(ctx context.Context, id uint64) (model.SocialMedia, error)
-	GetSocialMediasByUserID(ctx context.Context, userID uint64) ([]model.SocialMedia, error)
+	GetSocialMediasByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.SocialMedia], error)
 	CreateSocialMedia(ctx context.Context, socialMedia model.CreateSocialMedia) (model.CreateSocialMedia, error)
 	UpdateSocialMedia(ctx context.Context, id uint64, socialMedia model.UpdateSocialMedia) (model.UpdateSocialMedia, error)
 	DeleteSocialMediaByID(ctx context.Context, id uint64) error
@@ -35,35 +38,41 @@ func (s *socialMediaQueryImpl) GetSocialMediaByID(ctx context.Context, id uint64
 		Table("social_medias").
 		Where("id = ?", id).
 		First(&socialMedia).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.SocialMedia{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.SocialMedia{}, apperror.ErrNotFound
 		}
 		return model.SocialMedia{}, err
 	}
 	return socialMedia, nil
 }
-func (c *socialMediaQueryImpl) GetSocialMedias(ctx context.Context) ([]model.SocialMedia, error) {
-	db := c.db.GetConnection()
+func (c *socialMediaQueryImpl) GetSocialMedias(ctx context.Context, params pagination.Params) (pagination.Page[model.SocialMedia], error) {
+	db := c.db.GetReadConnection()
 	socialMedias := []model.SocialMedia{}
 	if err := db.
 		WithContext(ctx).
 		Table("social_medias").
+		Scopes(pagination.Scope(params, "social_medias")).
 		Find(&socialMedias).Error; err != nil {
-		return nil, err
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-	return socialMedias, nil
+	return pagination.NewPage(socialMedias, params, socialMediaCursor), nil
 }
-func (c *socialMediaQueryImpl) GetSocialMediasByUserID(ctx context.Context, userID uint64) ([]model.SocialMedia, error) {
-	db := c.db.GetConnection()
+func (c *socialMediaQueryImpl) GetSocialMediasByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.SocialMedia], error) {
+	db := c.db.GetReadConnection()
 	socialMedias := []model.SocialMedia{}
 	if err := db.
 		WithContext(ctx).
 		Table("social_medias").
 		Where("user_id = ?", userID).
+		Scopes(pagination.Scope(params, "social_medias")).
 		Find(&socialMedias).Error; err != nil {
-		return nil, err
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-	return socialMedias, nil
+	return pagination.NewPage(socialMedias, params, socialMediaCursor), nil
+}
+
+func socialMediaCursor(s model.SocialMedia) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: s.CreatedAt, ID: s.ID}
 }
 func (c *socialMediaQueryImpl) DeleteSocialMediaByID(ctx context.Context, id uint64) error {
 	db := c.db.GetConnection()
@@ -83,8 +92,8 @@ func (c *socialMediaQueryImpl) GetSocialMediaByID1(ctx context.Context, id uint6
 		Table("social_medias").
 		Where("id = ?", id).
 		First(&socialMedia).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdateSocialMedia{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdateSocialMedia{}, apperror.ErrNotFound
 		}
 		return model.UpdateSocialMedia{}, err
 	}
@@ -110,8 +119,8 @@ func (c *socialMediaQueryImpl) UpdateSocialMedia(ctx context.Context, id uint64,
 		Where("id = ?", id).
 		Updates(&socialMedia).
 		First(&updatedSocialMedia).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.UpdateSocialMedia{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.UpdateSocialMedia{}, apperror.ErrNotFound
 		}
 		return model.UpdateSocialMedia{}, err
 	}
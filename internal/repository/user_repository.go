@@ -2,20 +2,34 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/infrastructure"
 	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserQuery interface {
-	GetUsers(ctx context.Context) ([]model.User, error)
+	GetUsers(ctx context.Context, params pagination.Params) (pagination.Page[model.User], error)
 	GetUsersByID(ctx context.Context, id uint64) (model.User, error)
+	GetUsersByIDs(ctx context.Context, ids []uint64) ([]model.User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]model.User, error)
 	EditUser(ctx context.Context, id uint64, photo model.User) (model.User, error)
 	DeleteUsersByID(ctx context.Context, id uint64) error
+	UpdateUserRole(ctx context.Context, id uint64, role string) error
 
 	SignUp(ctx context.Context, user model.User) (model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+
+	FollowUser(ctx context.Context, followerID uint64, followingID uint64) (bool, error)
+	UnfollowUser(ctx context.Context, followerID uint64, followingID uint64) (bool, error)
+	GetFollowers(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error)
+	GetFollowing(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error)
+	CountFollows(ctx context.Context, id uint64) (followers int64, following int64, err error)
 }
 
 type userQueryImpl struct {
@@ -26,16 +40,19 @@ func NewUserQuery(db infrastructure.GormPostgres) UserQuery {
 	return &userQueryImpl{db: db}
 }
 
-func (u *userQueryImpl) GetUsers(ctx context.Context) ([]model.User, error) {
-	db := u.db.GetConnection()
+func (u *userQueryImpl) GetUsers(ctx context.Context, params pagination.Params) (pagination.Page[model.User], error) {
+	db := u.db.GetReadConnection()
 	users := []model.User{}
 	if err := db.
 		WithContext(ctx).
 		Table("users").
+		Scopes(pagination.Scope(params, "users")).
 		Find(&users).Error; err != nil {
-		return nil, err
+		return pagination.Page[model.User]{}, err
 	}
-	return users, nil
+	return pagination.NewPage(users, params, func(u model.User) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+	}), nil
 }
 
 func (u *userQueryImpl) GetUsersByID(ctx context.Context, id uint64) (model.User, error) {
@@ -45,9 +62,9 @@ func (u *userQueryImpl) GetUsersByID(ctx context.Context, id uint64) (model.User
 		WithContext(ctx).
 		Table("users").
 		Where("id = ?", id).
-		Find(&users).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.User{}, nil
+		Take(&users).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, apperror.ErrNotFound
 		}
 
 		return model.User{}, err
@@ -55,12 +72,51 @@ func (u *userQueryImpl) GetUsersByID(ctx context.Context, id uint64) (model.User
 	return users, nil
 }
 
+// GetUsersByIDs loads many users at once. Unknown IDs are left out.
+func (u *userQueryImpl) GetUsersByIDs(ctx context.Context, ids []uint64) ([]model.User, error) {
+	users := []model.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+	db := u.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("users").
+		Where("id IN ?", ids).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUsersByUsernames matches usernames case-insensitively.
+func (u *userQueryImpl) GetUsersByUsernames(ctx context.Context, usernames []string) ([]model.User, error) {
+	users := []model.User{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	lower := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		lower = append(lower, strings.ToLower(username))
+	}
+
+	db := u.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("users").
+		Where("LOWER(username) IN ?", lower).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (u *userQueryImpl) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
 	db := u.db.GetConnection()
 	user := model.User{}
-	if err := db.WithContext(ctx).Where("email = ?", email).Find(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.User{}, nil
+	if err := db.WithContext(ctx).Where("email = ?", email).Take(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, apperror.ErrNotFound
 		}
 		return model.User{}, err
 	}
@@ -74,9 +130,10 @@ func (u *userQueryImpl) EditUser(ctx context.Context, id uint64, photo model.Use
 		WithContext(ctx).
 		Table("users").
 		Where("id = ?", id).Updates(&photo).First(&updatedUser).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return model.User{}, nil
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, apperror.ErrNotFound
 		}
+		return model.User{}, err
 	}
 	return updatedUser, nil
 }
@@ -103,3 +160,89 @@ func (u *userQueryImpl) SignUp(ctx context.Context, user model.User) (model.User
 	}
 	return user, nil
 }
+
+func (u *userQueryImpl) UpdateUserRole(ctx context.Context, id uint64, role string) error {
+	db := u.db.GetConnection()
+	if err := db.
+		WithContext(ctx).
+		Table("users").
+		Where("id = ?", id).
+		Update("role", role).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FollowUser reports false when the follow already existed.
+func (u *userQueryImpl) FollowUser(ctx context.Context, followerID uint64, followingID uint64) (bool, error) {
+	db := u.db.GetConnection()
+	follow := model.Follow{FollowerID: followerID, FollowingID: followingID}
+	result := db.
+		WithContext(ctx).
+		Table("follows").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&follow)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UnfollowUser reports false when there was no follow to remove.
+func (u *userQueryImpl) UnfollowUser(ctx context.Context, followerID uint64, followingID uint64) (bool, error) {
+	db := u.db.GetConnection()
+	result := db.
+		WithContext(ctx).
+		Table("follows").
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Delete(&model.Follow{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (u *userQueryImpl) GetFollowers(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error) {
+	return u.getFollows(ctx, "following_id", "follower_id", id, params)
+}
+
+func (u *userQueryImpl) GetFollowing(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error) {
+	return u.getFollows(ctx, "follower_id", "following_id", id, params)
+}
+
+// getFollows lists the users on the other side of id's follows, most
+// recently followed first.
+func (u *userQueryImpl) getFollows(ctx context.Context, column string, other string, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error) {
+	db := u.db.GetReadConnection()
+	users := []model.FollowUser{}
+	if err := db.
+		WithContext(ctx).
+		Table("follows").
+		Select("follows.id AS follow_id, follows.created_at AS followed_at, users.id, users.username").
+		Joins("JOIN users ON users.id = follows."+other+" AND users.deleted_at IS NULL").
+		Where("follows."+column+" = ?", id).
+		Scopes(pagination.Scope(params, "follows")).
+		Find(&users).Error; err != nil {
+		return pagination.Page[model.FollowUser]{}, err
+	}
+	return pagination.NewPage(users, params, func(f model.FollowUser) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: f.FollowedAt, ID: f.FollowID}
+	}), nil
+}
+
+func (u *userQueryImpl) CountFollows(ctx context.Context, id uint64) (int64, int64, error) {
+	db := u.db.GetReadConnection()
+	counts := struct {
+		Followers int64
+		Following int64
+	}{}
+	if err := db.
+		WithContext(ctx).
+		Raw(`SELECT
+			(SELECT COUNT(*) FROM follows JOIN users ON users.id = follows.follower_id AND users.deleted_at IS NULL WHERE follows.following_id = ?) AS followers,
+			(SELECT COUNT(*) FROM follows JOIN users ON users.id = follows.following_id AND users.deleted_at IS NULL WHERE follows.follower_id = ?) AS following`, id, id).
+		Scan(&counts).Error; err != nil {
+		return 0, 0, err
+	}
+	return counts.Followers, counts.Following, nil
+}
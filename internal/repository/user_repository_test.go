@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/model"
+)
+
+func TestEditUserErrors(t *testing.T) {
+	errConn := errors.New("connection reset")
+	tests := []struct {
+		name   string
+		expect func(mock sqlmock.Sqlmock)
+		want   error
+	}{
+		{"update fails", func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "users"`).WillReturnError(errConn)
+			mock.ExpectRollback()
+		}, errConn},
+		{"user gone", func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "users"`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+			mock.ExpectQuery(`SELECT .* FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		}, apperror.ErrNotFound},
+		{"reload fails", func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "users"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			mock.ExpectQuery(`SELECT .* FROM "users"`).WillReturnError(errConn)
+		}, errConn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockPostgres(t)
+			tt.expect(mock)
+
+			user, err := NewUserQuery(db).EditUser(context.Background(), 1, model.User{Username: "alice"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v (%v)", err, tt.want, mock.ExpectationsWereMet())
+			}
+			if user.ID != 0 {
+				t.Errorf("got user %+v along with the error", user)
+			}
+		})
+	}
+}
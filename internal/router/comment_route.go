@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/geedotrar/mygram/internal/handler"
 	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,21 +14,23 @@ type CommentRouter interface {
 type commentRouterImpl struct {
 	v       *gin.RouterGroup
 	handler handler.CommentHandler
+	auth    middleware.AuthMiddleware
 }
 
-func NewCommentRouter(v *gin.RouterGroup, handler handler.CommentHandler) CommentRouter {
-	return &commentRouterImpl{v: v, handler: handler}
+func NewCommentRouter(v *gin.RouterGroup, handler handler.CommentHandler, auth middleware.AuthMiddleware) CommentRouter {
+	return &commentRouterImpl{v: v, handler: handler, auth: auth}
 }
 
 func (c *commentRouterImpl) Mount() {
-	c.v.Use(middleware.CheckAuthBearer)
+	c.v.Use(c.auth.CheckAuthBearer)
 
 	c.v.GET("/:id", c.handler.GetCommentByID)
+	c.v.GET("/:id/replies", c.handler.GetCommentReplies)
 	c.v.GET("", c.handler.GetCommentsByPhotoID)
 
 	c.v.POST("", c.handler.CreateComment)
 
-	c.v.PUT("/:id", c.handler.UpdateComment)
+	c.v.PUT("/:id", middleware.RequireOwnerOr(c.handler.CommentOwner), c.handler.UpdateComment)
 
-	c.v.DELETE("/:id", c.handler.DeleteComment)
+	c.v.DELETE("/:id", middleware.RequireOwnerOr(c.handler.CommentOwner, model.RoleModerator, model.RoleAdmin), c.handler.DeleteComment)
 }
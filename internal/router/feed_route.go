@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type FeedRouter interface {
+	Mount()
+}
+
+type feedRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.PhotoHandler
+	auth    middleware.AuthMiddleware
+}
+
+func NewFeedRouter(v *gin.RouterGroup, handler handler.PhotoHandler, auth middleware.AuthMiddleware) FeedRouter {
+	return &feedRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (f *feedRouterImpl) Mount() {
+	f.v.Use(f.auth.CheckAuthBearer)
+
+	f.v.GET("", f.handler.GetFeed)
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthRouter interface {
+	Mount()
+}
+
+type healthRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.HealthHandler
+}
+
+func NewHealthRouter(v *gin.RouterGroup, handler handler.HealthHandler) HealthRouter {
+	return &healthRouterImpl{v: v, handler: handler}
+}
+
+func (h *healthRouterImpl) Mount() {
+	h.v.GET("/healthz", h.handler.Healthz)
+	h.v.GET("/readyz", h.handler.Readyz)
+	h.v.GET("/version", h.handler.Version)
+}
@@ -0,0 +1,24 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/gin-gonic/gin"
+)
+
+type KeyRouter interface {
+	Mount()
+}
+
+type keyRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.KeyHandler
+}
+
+func NewKeyRouter(v *gin.RouterGroup, handler handler.KeyHandler) KeyRouter {
+	return &keyRouterImpl{v: v, handler: handler}
+}
+
+func (k *keyRouterImpl) Mount() {
+	// /.well-known/jwks.json
+	k.v.GET("/jwks.json", k.handler.GetJWKS)
+}
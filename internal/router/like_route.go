@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type LikeRouter interface {
+	Mount()
+}
+
+type likeRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.LikeHandler
+	auth    middleware.AuthMiddleware
+}
+
+// NewLikeRouter mounts the like routes of photos and comments on v, which is
+// expected to be the root group.
+func NewLikeRouter(v *gin.RouterGroup, handler handler.LikeHandler, auth middleware.AuthMiddleware) LikeRouter {
+	return &likeRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (l *likeRouterImpl) Mount() {
+	l.v.Use(l.auth.CheckAuthBearer)
+
+	// /photos/:id
+	l.v.POST("/photos/:id/like", l.handler.LikePhoto)
+	l.v.DELETE("/photos/:id/like", l.handler.UnlikePhoto)
+	l.v.GET("/photos/:id/likes", l.handler.GetPhotoLikes)
+	// /comments/:id
+	l.v.POST("/comments/:id/like", l.handler.LikeComment)
+	l.v.DELETE("/comments/:id/like", l.handler.UnlikeComment)
+}
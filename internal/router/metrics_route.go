@@ -0,0 +1,24 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsRouter interface {
+	Mount()
+}
+
+type metricsRouterImpl struct {
+	v       *gin.RouterGroup
+	handler http.Handler
+}
+
+func NewMetricsRouter(v *gin.RouterGroup, handler http.Handler) MetricsRouter {
+	return &metricsRouterImpl{v: v, handler: handler}
+}
+
+func (m *metricsRouterImpl) Mount() {
+	m.v.GET("/metrics", gin.WrapH(m.handler))
+}
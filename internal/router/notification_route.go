@@ -0,0 +1,28 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationRouter interface {
+	Mount()
+}
+
+type notificationRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.NotificationHandler
+	auth    middleware.AuthMiddleware
+}
+
+func NewNotificationRouter(v *gin.RouterGroup, handler handler.NotificationHandler, auth middleware.AuthMiddleware) NotificationRouter {
+	return &notificationRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (n *notificationRouterImpl) Mount() {
+	n.v.Use(n.auth.CheckAuthBearer)
+
+	n.v.GET("", n.handler.GetNotifications)
+	n.v.POST("/read", n.handler.MarkNotificationsRead)
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/geedotrar/mygram/internal/handler"
 	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,21 +14,26 @@ type PhotoRouter interface {
 type photoRouterImpl struct {
 	v       *gin.RouterGroup
 	handler handler.PhotoHandler
+	auth    middleware.AuthMiddleware
 }
 
-func NewPhotoRouter(v *gin.RouterGroup, handler handler.PhotoHandler) PhotoRouter {
-	return &photoRouterImpl{v: v, handler: handler}
+func NewPhotoRouter(v *gin.RouterGroup, handler handler.PhotoHandler, auth middleware.AuthMiddleware) PhotoRouter {
+	return &photoRouterImpl{v: v, handler: handler, auth: auth}
 }
 
 func (p *photoRouterImpl) Mount() {
-	p.v.Use(middleware.CheckAuthBearer)
+	// images are public so they can be used directly in <img> tags
+	p.v.GET("/:id/image", p.handler.GetPhotoImage)
+	p.v.GET("/:id/image/:variant", p.handler.GetPhotoImage)
+
+	p.v.Use(p.auth.CheckAuthBearer)
 
 	p.v.GET("", p.handler.GetPhotos)
 	p.v.GET("/:id", p.handler.GetPhotoByID)
 	p.v.GET("/user", p.handler.GetPhotoByUserID)
 
 	p.v.POST("", p.handler.CreatePhoto)
-	p.v.PUT("/:id", p.handler.UpdatePhoto)
-	p.v.DELETE("/:id", p.handler.DeletePhotoByID)
+	p.v.PUT("/:id", middleware.RequireOwnerOr(p.handler.PhotoOwner), p.handler.UpdatePhoto)
+	p.v.DELETE("/:id", middleware.RequireOwnerOr(p.handler.PhotoOwner, model.RoleModerator, model.RoleAdmin), p.handler.DeletePhotoByID)
 
 }
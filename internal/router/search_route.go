@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchRouter interface {
+	Mount()
+}
+
+type searchRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.SearchHandler
+	auth    middleware.AuthMiddleware
+}
+
+func NewSearchRouter(v *gin.RouterGroup, handler handler.SearchHandler, auth middleware.AuthMiddleware) SearchRouter {
+	return &searchRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (s *searchRouterImpl) Mount() {
+	s.v.Use(s.auth.CheckAuthBearer)
+
+	s.v.GET("", s.handler.Search)
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/geedotrar/mygram/internal/handler"
 	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,14 +14,15 @@ type SocialMediaRouter interface {
 type socialMediaRouterImpl struct {
 	v       *gin.RouterGroup
 	handler handler.SocialMediaHandler
+	auth    middleware.AuthMiddleware
 }
 
-func NewSocialMediaRouter(v *gin.RouterGroup, handler handler.SocialMediaHandler) SocialMediaRouter {
-	return &socialMediaRouterImpl{v: v, handler: handler}
+func NewSocialMediaRouter(v *gin.RouterGroup, handler handler.SocialMediaHandler, auth middleware.AuthMiddleware) SocialMediaRouter {
+	return &socialMediaRouterImpl{v: v, handler: handler, auth: auth}
 }
 
 func (c *socialMediaRouterImpl) Mount() {
-	c.v.Use(middleware.CheckAuthBearer)
+	c.v.Use(c.auth.CheckAuthBearer)
 
 	c.v.GET("/:id", c.handler.GetSocialMediaByID)
 	c.v.GET("", c.handler.GetSocialMediasByUserID)
@@ -28,7 +30,7 @@ func (c *socialMediaRouterImpl) Mount() {
 	c.v.POST("", c.handler.CreateSocialMedia)
 	// c.v.GET("", c.handler.GetSocialMedias)
 
-	c.v.PUT("/:id", c.handler.UpdateSocialMedia)
+	c.v.PUT("/:id", middleware.RequireOwnerOr(c.handler.SocialMediaOwner), c.handler.UpdateSocialMedia)
 
-	c.v.DELETE("/:id", c.handler.DeleteSocialMedia)
+	c.v.DELETE("/:id", middleware.RequireOwnerOr(c.handler.SocialMediaOwner, model.RoleAdmin), c.handler.DeleteSocialMedia)
 }
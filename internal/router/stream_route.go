@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type StreamRouter interface {
+	Mount()
+}
+
+type streamRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.StreamHandler
+	auth    middleware.AuthMiddleware
+}
+
+func NewStreamRouter(v *gin.RouterGroup, handler handler.StreamHandler, auth middleware.AuthMiddleware) StreamRouter {
+	return &streamRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (s *streamRouterImpl) Mount() {
+	s.v.Use(s.auth.CheckAuthBearer)
+
+	s.v.GET("", s.handler.Stream)
+}
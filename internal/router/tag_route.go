@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/geedotrar/mygram/internal/handler"
+	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type TagRouter interface {
+	Mount()
+}
+
+type tagRouterImpl struct {
+	v       *gin.RouterGroup
+	handler handler.PhotoHandler
+	auth    middleware.AuthMiddleware
+}
+
+func NewTagRouter(v *gin.RouterGroup, handler handler.PhotoHandler, auth middleware.AuthMiddleware) TagRouter {
+	return &tagRouterImpl{v: v, handler: handler, auth: auth}
+}
+
+func (t *tagRouterImpl) Mount() {
+	t.v.Use(t.auth.CheckAuthBearer)
+
+	t.v.GET("/:tag/photos", t.handler.GetPhotosByTag)
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/geedotrar/mygram/internal/handler"
 	"github.com/geedotrar/mygram/internal/middleware"
+	"github.com/geedotrar/mygram/internal/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,23 +14,33 @@ type UserRouter interface {
 type userRouterImpl struct {
 	v       *gin.RouterGroup
 	handler handler.UserHandler
+	auth    middleware.AuthMiddleware
 }
 
-func NewUserRouter(v *gin.RouterGroup, handler handler.UserHandler) UserRouter {
-	return &userRouterImpl{v: v, handler: handler}
+func NewUserRouter(v *gin.RouterGroup, handler handler.UserHandler, auth middleware.AuthMiddleware) UserRouter {
+	return &userRouterImpl{v: v, handler: handler, auth: auth}
 }
 
 func (u *userRouterImpl) Mount() {
 	// activity
 	u.v.POST("/register", u.handler.UserSignUp)
 	u.v.POST("/login", u.handler.UserLogin)
+	u.v.POST("/token/refresh", u.handler.RefreshToken)
 
 	// users
-	u.v.Use(middleware.CheckAuthBearer)
+	u.v.Use(u.auth.CheckAuthBearer)
+	u.v.POST("/logout", u.handler.UserLogout)
 	// /users
-	u.v.GET("", u.handler.GetUsers)
+	u.v.GET("", middleware.RequireRole(model.RoleAdmin), u.handler.GetUsers)
 	// /users/:id
 	u.v.GET("/:id", u.handler.GetUsersByID)
-	u.v.PUT("/:id", u.handler.EditUser)
-	u.v.DELETE("/:id", u.handler.DeleteUsersById)
+	u.v.PUT("/:id", middleware.RequireOwnerOr(middleware.ParamOwner("id")), u.handler.EditUser)
+	u.v.DELETE("/:id", middleware.RequireOwnerOr(middleware.ParamOwner("id"), model.RoleAdmin), u.handler.DeleteUsersById)
+	u.v.PUT("/:id/role", middleware.RequireRole(model.RoleAdmin), u.handler.UpdateUserRole)
+	// /users/:id/follow
+	u.v.POST("/:id/follow", u.handler.FollowUser)
+	u.v.DELETE("/:id/follow", u.handler.UnfollowUser)
+	u.v.GET("/:id/followers", u.handler.GetFollowers)
+	u.v.GET("/:id/following", u.handler.GetFollowing)
+	u.v.GET("/:id/mentions", u.handler.GetMentions)
 }
@@ -2,108 +2,110 @@ package service
 
 import (
 	"context"
+	"errors"
 
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/entity"
+	"github.com/geedotrar/mygram/pkg/pagination"
 )
 
 type CommentService interface {
-	GetCommentByID(ctx context.Context, id uint64) (model.GetCommentByID, error)
+	GetCommentByID(ctx context.Context, id uint64, viewerID uint64) (model.GetCommentByID, error)
 	DeleteCommentByID(ctx context.Context, id uint64) (model.UpdateComment, error)
 	CreateComment(ctx context.Context, comment model.CreateComment, user uint64) (model.CreateComment, error)
 	UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment) (model.UpdateComment, error)
-	GetCommentsByPhotoID(ctx context.Context, photoID uint64) ([]model.Comment, error)
+	GetCommentsByPhotoID(ctx context.Context, photoID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.Comment], error)
+	GetCommentReplies(ctx context.Context, parentID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.Comment], error)
 	GetCommentByID1(ctx context.Context, id uint64) (model.UpdateComment, error)
-	GetComments(ctx context.Context) ([]model.GetCommentByID, error)
+	GetComments(ctx context.Context, viewerID uint64, params pagination.Params) (pagination.Page[model.GetCommentByID], error)
 }
 
+var (
+	ErrCommentNotFound       = apperror.NotFound("comment_not_found", "comment not found")
+	ErrParentCommentNotFound = apperror.NotFound("parent_comment_not_found", "parent comment not found")
+	ErrParentCommentMismatch = apperror.Field("parent_id", "parent comment belongs to another photo")
+)
+
 type commentServiceImpl struct {
 	repoComment repository.CommentQuery
 	repoUser    repository.UserQuery
 	repoPhoto   repository.PhotoQuery
+	repoLike    repository.LikeQuery
+	repoEntity  repository.EntityQuery
+	bus         *event.Bus
 }
 
-func NewCommentService(repoComment repository.CommentQuery, repoUser repository.UserQuery, repoPhoto repository.PhotoQuery) CommentService {
+func NewCommentService(repoComment repository.CommentQuery, repoUser repository.UserQuery, repoPhoto repository.PhotoQuery, repoLike repository.LikeQuery, repoEntity repository.EntityQuery, bus *event.Bus) CommentService {
 	return &commentServiceImpl{
 		repoComment: repoComment,
 		repoUser:    repoUser,
 		repoPhoto:   repoPhoto,
+		repoLike:    repoLike,
+		repoEntity:  repoEntity,
+		bus:         bus,
 	}
 }
 
-func (c *commentServiceImpl) GetCommentByID(ctx context.Context, id uint64) (model.GetCommentByID, error) {
+func (c *commentServiceImpl) GetCommentByID(ctx context.Context, id uint64, viewerID uint64) (model.GetCommentByID, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetCommentByID")
+	defer span.End()
+
 	comment, err := c.repoComment.GetCommentByID(ctx, id)
-	if err != nil {
-		return model.GetCommentByID{}, err
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.GetCommentByID{}, ErrCommentNotFound
 	}
-	user, err := c.repoUser.GetUsersByID(ctx, comment.UserID)
 	if err != nil {
 		return model.GetCommentByID{}, err
 	}
-
-	comment.User.ID = user.ID
-	comment.User.Email = user.Email
-	comment.User.Username = user.Username
-
-	photo, err := c.repoPhoto.GetPhotoByID(ctx, comment.PhotoID)
-	if err != nil {
+	comments := []model.GetCommentByID{comment}
+	if err := c.fillComments(ctx, viewerID, comments); err != nil {
 		return model.GetCommentByID{}, err
 	}
-	comment.Photo.ID = photo.ID
-	comment.Photo.Title = photo.Title
-	comment.Photo.Caption = photo.Caption
-	comment.Photo.PhotoURL = photo.PhotoURL
-	comment.Photo.UserID = photo.UserID
-
-	return comment, err
+	return comments[0], nil
 }
 func (c *commentServiceImpl) GetCommentByID1(ctx context.Context, id uint64) (model.UpdateComment, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetCommentByID1")
+	defer span.End()
+
 	comment, err := c.repoComment.GetCommentByID1(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateComment{}, ErrCommentNotFound
+	}
 	if err != nil {
 		return model.UpdateComment{}, err
 	}
 	return comment, err
 }
-func (c *commentServiceImpl) GetComments(ctx context.Context) ([]model.GetCommentByID, error) {
-	comments, err := c.repoComment.GetComments(ctx)
+func (c *commentServiceImpl) GetComments(ctx context.Context, viewerID uint64, params pagination.Params) (pagination.Page[model.GetCommentByID], error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetComments")
+	defer span.End()
+
+	page, err := c.repoComment.GetComments(ctx, params)
 	if err != nil {
-		return nil, err
+		return pagination.Page[model.GetCommentByID]{}, err
 	}
 
-	for i, comment := range comments {
-		user, err := c.repoUser.GetUsersByID(ctx, comment.UserID)
-
-		if err != nil {
-			return nil, err
-		}
-
-		comments[i].User.Email = user.Email
-		comments[i].User.Username = user.Username
-		comments[i].User.ID = user.ID
-
-		photo, err := c.repoPhoto.GetPhotoByID(ctx, comment.PhotoID)
-		if err != nil {
-			return nil, err
-		}
-		comments[i].Photo.ID = photo.ID
-		comments[i].Photo.Title = photo.Title
-		comments[i].Photo.Caption = photo.Caption
-		comments[i].Photo.PhotoURL = photo.PhotoURL
-		comments[i].Photo.UserID = photo.UserID
+	if err := c.fillComments(ctx, viewerID, page.Data); err != nil {
+		return pagination.Page[model.GetCommentByID]{}, err
 	}
-
-	return comments, nil
+	return page, nil
 }
 func (c *commentServiceImpl) DeleteCommentByID(ctx context.Context, id uint64) (model.UpdateComment, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.DeleteCommentByID")
+	defer span.End()
+
 	comment, err := c.repoComment.GetCommentByID1(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateComment{}, ErrCommentNotFound
+	}
 	if err != nil {
 		return model.UpdateComment{}, err
 	}
 
-	if comment.ID == 0 {
-		return model.UpdateComment{}, nil
-	}
-
 	err = c.repoComment.DeleteCommentByID(ctx, id)
 	if err != nil {
 		return model.UpdateComment{}, err
@@ -113,50 +115,212 @@ func (c *commentServiceImpl) DeleteCommentByID(ctx context.Context, id uint64) (
 }
 
 func (c *commentServiceImpl) CreateComment(ctx context.Context, CreateComment model.CreateComment, userID uint64) (model.CreateComment, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.CreateComment")
+	defer span.End()
+
 	comment := model.CreateComment{
-		Message: CreateComment.Message,
-		PhotoID: CreateComment.PhotoID,
-		UserID:  userID,
+		Message:  CreateComment.Message,
+		PhotoID:  CreateComment.PhotoID,
+		ParentID: CreateComment.ParentID,
+		UserID:   userID,
+	}
+	photo, err := c.repoPhoto.GetPhotoByID(ctx, comment.PhotoID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.CreateComment{}, ErrPhotoNotFound
+	}
+	if err != nil {
+		return model.CreateComment{}, err
+	}
+	parent := model.UpdateComment{}
+	if comment.ParentID != nil {
+		parent, err = c.repoComment.GetCommentByID1(ctx, *comment.ParentID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return model.CreateComment{}, ErrParentCommentNotFound
+		}
+		if err != nil {
+			return model.CreateComment{}, err
+		}
+		if parent.PhotoID != comment.PhotoID {
+			return model.CreateComment{}, ErrParentCommentMismatch
+		}
 	}
-	createdComment, err := c.repoComment.CreateComment(ctx, comment)
+	entities, err := resolveEntities(ctx, c.repoUser, comment.Message)
 	if err != nil {
 		return model.CreateComment{}, err
 	}
+	tags, userIDs := entityLinks(entities)
+	createdComment, err := c.repoComment.CreateComment(ctx, comment, tags, userIDs)
+	if err != nil {
+		return model.CreateComment{}, err
+	}
+	createdComment.Entities = entities
+
+	c.bus.Publish(event.Event{
+		Type:       event.TYPE_PHOTO_COMMENTED,
+		ActorID:    userID,
+		TargetType: model.TargetComment,
+		TargetID:   createdComment.ID,
+		PhotoID:    createdComment.PhotoID,
+	})
+
+	// tell everyone involved once, the most specific reason winning
+	notified := map[uint64]bool{}
+	if parent.ID != 0 {
+		notified[parent.UserID] = true
+		c.bus.Publish(event.Event{
+			Type:        event.TYPE_REPLY_CREATED,
+			ActorID:     userID,
+			RecipientID: parent.UserID,
+			TargetType:  model.TargetComment,
+			TargetID:    createdComment.ID,
+			PhotoID:     createdComment.PhotoID,
+		})
+	}
+	if !notified[photo.UserID] {
+		notified[photo.UserID] = true
+		c.bus.Publish(event.Event{
+			Type:        event.TYPE_COMMENT_CREATED,
+			ActorID:     userID,
+			RecipientID: photo.UserID,
+			TargetType:  model.TargetComment,
+			TargetID:    createdComment.ID,
+			PhotoID:     createdComment.PhotoID,
+		})
+	}
+	publishMentions(c.bus, userID, model.TargetComment, createdComment.ID, createdComment.PhotoID, createdComment.Entities, notified)
 	return createdComment, nil
 }
 
+// UpdateComment edits the message of a comment. Its author, photo and
+// parent never change, whatever else comment carries.
 func (c *commentServiceImpl) UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment) (model.UpdateComment, error) {
-	updatedComment, err := c.repoComment.UpdateComment(ctx, id, comment)
+	ctx, span := tracing.Start(ctx, "CommentService.UpdateComment")
+	defer span.End()
+
+	comment = model.UpdateComment{Message: comment.Message}
+
+	entities, err := resolveEntities(ctx, c.repoUser, comment.Message)
 	if err != nil {
 		return model.UpdateComment{}, err
 	}
+	tags, userIDs := entityLinks(entities)
+	notified, err := mentionedUsers(ctx, c.repoEntity, model.TargetComment, id)
+	if err != nil {
+		return model.UpdateComment{}, err
+	}
+	updatedComment, err := c.repoComment.UpdateComment(ctx, id, comment, tags, userIDs)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateComment{}, ErrCommentNotFound
+	}
+	if err != nil {
+		return model.UpdateComment{}, err
+	}
+	updatedComment.Entities = entities
+	// only the author edits a comment
+	publishMentions(c.bus, updatedComment.UserID, model.TargetComment, id, updatedComment.PhotoID, updatedComment.Entities, notified)
 	return updatedComment, nil
 }
-func (c *commentServiceImpl) GetCommentsByPhotoID(ctx context.Context, photoID uint64) ([]model.Comment, error) {
-	comments, err := c.repoComment.GetCommentsByPhotoID(ctx, photoID)
+
+func (c *commentServiceImpl) GetCommentsByPhotoID(ctx context.Context, photoID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.Comment], error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetCommentsByPhotoID")
+	defer span.End()
+
+	page, err := c.repoComment.GetCommentsByPhotoID(ctx, photoID, params)
 	if err != nil {
-		return nil, err
+		return pagination.Page[model.Comment]{}, err
 	}
-	for i, comment := range comments {
-		user, err := c.repoUser.GetUsersByID(ctx, comment.UserID)
+	if err := c.fillThread(ctx, viewerID, page.Data); err != nil {
+		return pagination.Page[model.Comment]{}, err
+	}
+	return page, nil
+}
 
-		if err != nil {
-			return nil, err
-		}
+func (c *commentServiceImpl) GetCommentReplies(ctx context.Context, parentID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.Comment], error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetCommentReplies")
+	defer span.End()
 
-		comments[i].User.Email = user.Email
-		comments[i].User.Username = user.Username
-		comments[i].User.ID = user.ID
+	page, err := c.repoComment.GetCommentReplies(ctx, parentID, params)
+	if err != nil {
+		return pagination.Page[model.Comment]{}, err
+	}
+	if err := c.fillThread(ctx, viewerID, page.Data); err != nil {
+		return pagination.Page[model.Comment]{}, err
+	}
+	return page, nil
+}
 
-		photo, err := c.repoPhoto.GetPhotoByID(ctx, comment.PhotoID)
-		if err != nil {
-			return nil, err
+// fillComments embeds the author, photo, likes and entities of each
+// comment, each with a single query.
+func (c *commentServiceImpl) fillComments(ctx context.Context, viewerID uint64, comments []model.GetCommentByID) error {
+	if err := fillUsers(ctx, c.repoUser, comments, func(comment *model.GetCommentByID) uint64 {
+		return comment.UserID
+	}, func(comment *model.GetCommentByID, user model.User) {
+		comment.User.ID = user.ID
+		comment.User.Email = user.Email
+		comment.User.Username = user.Username
+	}); err != nil {
+		return err
+	}
+	if err := fillPhotoRefs(ctx, c.repoPhoto, comments, func(comment *model.GetCommentByID) uint64 {
+		return comment.PhotoID
+	}, func(comment *model.GetCommentByID, photo model.UpdatePhoto) {
+		comment.Photo.ID = photo.ID
+		comment.Photo.Title = photo.Title
+		comment.Photo.Caption = photo.Caption
+		comment.Photo.PhotoURL = photo.PhotoURL
+		comment.Photo.UserID = photo.UserID
+	}); err != nil {
+		return err
+	}
+	if err := fillLikes(ctx, c.repoLike, model.TargetComment, viewerID, comments, func(comment *model.GetCommentByID) (uint64, *model.LikeSummary) {
+		return comment.ID, &comment.LikeSummary
+	}); err != nil {
+		return err
+	}
+	return fillEntities(ctx, c.repoEntity, model.TargetComment, comments, func(comment *model.GetCommentByID) (uint64, string, *[]entity.Entity) {
+		return comment.ID, comment.Message, &comment.Entities
+	})
+}
+
+// fillThread embeds the author, photo and likes of each comment, blanking
+// out deleted comments that are only listed to hold their replies.
+func (c *commentServiceImpl) fillThread(ctx context.Context, viewerID uint64, comments []model.Comment) error {
+	for i, comment := range comments {
+		if comment.DeletedAt.Valid {
+			comments[i].Deleted = true
+			comments[i].Message = ""
+			comments[i].UserID = 0
 		}
-		comments[i].Photo.ID = photo.ID
-		comments[i].Photo.Title = photo.Title
-		comments[i].Photo.Caption = photo.Caption
-		comments[i].Photo.PhotoURL = photo.PhotoURL
-		comments[i].Photo.UserID = photo.UserID
 	}
-	return comments, nil
+	if err := fillUsers(ctx, c.repoUser, comments, func(comment *model.Comment) uint64 {
+		return comment.UserID
+	}, func(comment *model.Comment, user model.User) {
+		comment.User.ID = user.ID
+		comment.User.Email = user.Email
+		comment.User.Username = user.Username
+	}); err != nil {
+		return err
+	}
+	if err := fillPhotoRefs(ctx, c.repoPhoto, comments, func(comment *model.Comment) uint64 {
+		if comment.Deleted {
+			return 0
+		}
+		return comment.PhotoID
+	}, func(comment *model.Comment, photo model.UpdatePhoto) {
+		comment.Photo.ID = photo.ID
+		comment.Photo.Title = photo.Title
+		comment.Photo.Caption = photo.Caption
+		comment.Photo.PhotoURL = photo.PhotoURL
+		comment.Photo.UserID = photo.UserID
+	}); err != nil {
+		return err
+	}
+	if err := fillLikes(ctx, c.repoLike, model.TargetComment, viewerID, comments, func(comment *model.Comment) (uint64, *model.LikeSummary) {
+		return comment.ID, &comment.LikeSummary
+	}); err != nil {
+		return err
+	}
+	return fillEntities(ctx, c.repoEntity, model.TargetComment, comments, func(comment *model.Comment) (uint64, string, *[]entity.Entity) {
+		return comment.ID, comment.Message, &comment.Entities
+	})
 }
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+)
+
+// usernameQuery knows a fixed set of users by username.
+type usernameQuery struct {
+	repository.UserQuery
+	users []model.User
+}
+
+func (q *usernameQuery) GetUsersByUsernames(ctx context.Context, usernames []string) ([]model.User, error) {
+	found := []model.User{}
+	for _, user := range q.users {
+		for _, username := range usernames {
+			if strings.EqualFold(user.Username, username) {
+				found = append(found, user)
+			}
+		}
+	}
+	return found, nil
+}
+
+// commentStore keeps comments in memory like the comments table, writing
+// the non-zero fields of an update.
+type commentStore struct {
+	repository.CommentQuery
+	comments map[uint64]model.UpdateComment
+}
+
+func (q *commentStore) UpdateComment(ctx context.Context, id uint64, comment model.UpdateComment, tags []string, userIDs []uint64) (model.UpdateComment, error) {
+	stored, ok := q.comments[id]
+	if !ok {
+		return model.UpdateComment{}, apperror.ErrNotFound
+	}
+	if comment.Message != "" {
+		stored.Message = comment.Message
+	}
+	if comment.UserID != 0 {
+		stored.UserID = comment.UserID
+	}
+	if comment.PhotoID != 0 {
+		stored.PhotoID = comment.PhotoID
+	}
+	q.comments[id] = stored
+	return stored, nil
+}
+
+// mentionsQuery knows which users each target mentions.
+type mentionsQuery struct {
+	repository.EntityQuery
+	mentioned map[uint64][]model.MentionedUser
+}
+
+func (q *mentionsQuery) GetMentionedUsers(ctx context.Context, targetType string, targetIDs []uint64) (map[uint64][]model.MentionedUser, error) {
+	users := map[uint64][]model.MentionedUser{}
+	for _, id := range targetIDs {
+		users[id] = q.mentioned[id]
+	}
+	return users, nil
+}
+
+// recordEvents starts a bus and returns a function stopping it and
+// returning every event published in between.
+func recordEvents(t *testing.T) (*event.Bus, func() []event.Event) {
+	t.Helper()
+	bus := event.NewBus(1, 16)
+	var mu sync.Mutex
+	events := []event.Event{}
+	bus.Subscribe(func(ctx context.Context, e event.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+		return nil
+	})
+	bus.Start()
+	t.Cleanup(func() { bus.Stop(context.Background()) })
+	return bus, func() []event.Event {
+		if err := bus.Stop(context.Background()); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+}
+
+func (q *commentStore) CreateComment(ctx context.Context, comment model.CreateComment, tags []string, userIDs []uint64) (model.CreateComment, error) {
+	comment.ID = uint64(len(q.comments) + 1)
+	q.comments[comment.ID] = model.UpdateComment{ID: comment.ID, Message: comment.Message, UserID: comment.UserID, PhotoID: comment.PhotoID}
+	return comment, nil
+}
+
+func TestUpdateCommentOnlyEditsMessage(t *testing.T) {
+	repo := &commentStore{comments: map[uint64]model.UpdateComment{3: {ID: 3, Message: "hi", UserID: 1, PhotoID: 5}}}
+	svc := NewCommentService(repo, &usernameQuery{}, nil, nil, &mentionsQuery{}, event.NewBus(1, 1))
+
+	// the handler binds the request over the stored comment
+	updated, err := svc.UpdateComment(context.Background(), 3, model.UpdateComment{ID: 3, Message: "hello", UserID: 2, PhotoID: 6})
+	if err != nil {
+		t.Fatalf("UpdateComment: %v", err)
+	}
+	if got := repo.comments[3]; got.Message != "hello" || got.UserID != 1 || got.PhotoID != 5 {
+		t.Errorf("stored %+v, want the new message on photo 5 by user 1", got)
+	}
+	if updated.PhotoID != 5 || updated.UserID != 1 {
+		t.Errorf("returned %+v, want photo 5 by user 1", updated)
+	}
+}
+
+func TestCreateCommentOnMissingPhoto(t *testing.T) {
+	comments := &commentStore{comments: map[uint64]model.UpdateComment{}}
+	svc := NewCommentService(comments, &usernameQuery{}, &cascadePhotoQuery{}, nil, nil, event.NewBus(1, 1))
+
+	_, err := svc.CreateComment(context.Background(), model.CreateComment{Message: "hi", PhotoID: 7}, 1)
+	if err != ErrPhotoNotFound {
+		t.Fatalf("got error %v, want ErrPhotoNotFound", err)
+	}
+	if len(comments.comments) != 0 {
+		t.Errorf("stored %d comments for a missing photo", len(comments.comments))
+	}
+}
+
+func TestUpdateCommentMentionsNewUsersOnly(t *testing.T) {
+	repo := &commentStore{comments: map[uint64]model.UpdateComment{3: {ID: 3, Message: "hi @bob", UserID: 1, PhotoID: 5}}}
+	users := &usernameQuery{users: []model.User{{ID: 2, Username: "bob"}, {ID: 3, Username: "carol"}, {ID: 4, Username: "dave"}}}
+	entities := &mentionsQuery{mentioned: map[uint64][]model.MentionedUser{3: {{TargetID: 3, UserID: 2, Username: "bob"}}}}
+	bus, events := recordEvents(t)
+	svc := NewCommentService(repo, users, nil, nil, entities, bus)
+
+	if _, err := svc.UpdateComment(context.Background(), 3, model.UpdateComment{Message: "hi @bob @Carol @dave @carol"}); err != nil {
+		t.Fatalf("UpdateComment: %v", err)
+	}
+
+	recipients := []uint64{}
+	for _, e := range events() {
+		if e.Type != event.TYPE_MENTION_CREATED || e.ActorID != 1 || e.TargetType != model.TargetComment || e.TargetID != 3 || e.PhotoID != 5 {
+			t.Errorf("unexpected event %+v", e)
+		}
+		recipients = append(recipients, e.RecipientID)
+	}
+	if len(recipients) != 2 || recipients[0] != 3 || recipients[1] != 4 {
+		t.Errorf("notified %v, want carol and dave once", recipients)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/pkg/entity"
+)
+
+// resolveEntities parses the mentions and hashtags of text, dropping the
+// mentions of unknown users.
+func resolveEntities(ctx context.Context, repoUser repository.UserQuery, text string) ([]entity.Entity, error) {
+	entities := entity.Parse(text)
+	users, err := repoUser.GetUsersByUsernames(ctx, entity.Usernames(entities))
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := map[string]uint64{}
+	for _, user := range users {
+		key := strings.ToLower(user.Username)
+		if _, ok := userIDs[key]; !ok {
+			userIDs[key] = user.ID
+		}
+	}
+	return resolveMentions(entities, userIDs), nil
+}
+
+// mentionedUsers returns the users a stored photo or comment mentions, so
+// an edit only notifies the ones it adds.
+func mentionedUsers(ctx context.Context, repoEntity repository.EntityQuery, targetType string, targetID uint64) (map[uint64]bool, error) {
+	mentioned, err := repoEntity.GetMentionedUsers(ctx, targetType, []uint64{targetID})
+	if err != nil {
+		return nil, err
+	}
+	userIDs := map[uint64]bool{}
+	for _, user := range mentioned[targetID] {
+		userIDs[user.UserID] = true
+	}
+	return userIDs, nil
+}
+
+// entityLinks returns the distinct hashtags and mentioned users of
+// resolved entities, the rows stored for their target.
+func entityLinks(entities []entity.Entity) ([]string, []uint64) {
+	seen := map[uint64]bool{}
+	userIDs := []uint64{}
+	for _, e := range entities {
+		if e.Type == entity.TYPE_MENTION && !seen[e.UserID] {
+			seen[e.UserID] = true
+			userIDs = append(userIDs, e.UserID)
+		}
+	}
+	return entity.Tags(entities), userIDs
+}
+
+// fillEntities sets the entities of every item, resolving mentions with a
+// single query. target returns the ID and text of an item and the slice to
+// fill in.
+func fillEntities[T any](ctx context.Context, repoEntity repository.EntityQuery, targetType string, items []T, target func(*T) (uint64, string, *[]entity.Entity)) error {
+	ids := make([]uint64, 0, len(items))
+	for i := range items {
+		id, _, _ := target(&items[i])
+		ids = append(ids, id)
+	}
+	mentioned, err := repoEntity.GetMentionedUsers(ctx, targetType, ids)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		id, text, out := target(&items[i])
+		userIDs := map[string]uint64{}
+		for _, user := range mentioned[id] {
+			userIDs[strings.ToLower(user.Username)] = user.UserID
+		}
+		*out = resolveMentions(entity.Parse(text), userIDs)
+	}
+	return nil
+}
+
+// resolveMentions sets the user of each mention, dropping the ones that do
+// not point at a known user.
+func resolveMentions(entities []entity.Entity, userIDs map[string]uint64) []entity.Entity {
+	resolved := make([]entity.Entity, 0, len(entities))
+	for _, e := range entities {
+		if e.Type == entity.TYPE_MENTION {
+			userID, ok := userIDs[strings.ToLower(e.Text)]
+			if !ok {
+				continue
+			}
+			e.UserID = userID
+		}
+		resolved = append(resolved, e)
+	}
+	return resolved
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+var ErrLikeTargetNotFound = apperror.NotFound("like_target_not_found", "like target not found")
+
+type LikeService interface {
+	Like(ctx context.Context, userID uint64, targetType string, targetID uint64) error
+	Unlike(ctx context.Context, userID uint64, targetType string, targetID uint64) error
+	GetLikers(ctx context.Context, targetType string, targetID uint64, params pagination.Params) (pagination.Page[model.LikeUser], error)
+}
+
+type likeServiceImpl struct {
+	repoLike    repository.LikeQuery
+	repoPhoto   repository.PhotoQuery
+	repoComment repository.CommentQuery
+	bus         *event.Bus
+}
+
+func NewLikeService(repoLike repository.LikeQuery, repoPhoto repository.PhotoQuery, repoComment repository.CommentQuery, bus *event.Bus) LikeService {
+	return &likeServiceImpl{
+		repoLike:    repoLike,
+		repoPhoto:   repoPhoto,
+		repoComment: repoComment,
+		bus:         bus,
+	}
+}
+
+// Like is idempotent: liking something twice leaves a single like.
+func (l *likeServiceImpl) Like(ctx context.Context, userID uint64, targetType string, targetID uint64) error {
+	ctx, span := tracing.Start(ctx, "LikeService.Like")
+	defer span.End()
+
+	target, err := l.getTarget(ctx, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	created, err := l.repoLike.CreateLike(ctx, model.Like{
+		UserID:     userID,
+		TargetType: targetType,
+		TargetID:   targetID,
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		l.bus.Publish(event.Event{
+			Type:        event.TYPE_LIKE_CREATED,
+			ActorID:     userID,
+			RecipientID: target.ownerID,
+			TargetType:  targetType,
+			TargetID:    targetID,
+			PhotoID:     target.photoID,
+		})
+	}
+	return nil
+}
+
+// Unlike is idempotent: removing a like that does not exist succeeds.
+func (l *likeServiceImpl) Unlike(ctx context.Context, userID uint64, targetType string, targetID uint64) error {
+	ctx, span := tracing.Start(ctx, "LikeService.Unlike")
+	defer span.End()
+
+	if _, err := l.getTarget(ctx, targetType, targetID); err != nil {
+		return err
+	}
+	return l.repoLike.DeleteLike(ctx, userID, targetType, targetID)
+}
+
+func (l *likeServiceImpl) GetLikers(ctx context.Context, targetType string, targetID uint64, params pagination.Params) (pagination.Page[model.LikeUser], error) {
+	ctx, span := tracing.Start(ctx, "LikeService.GetLikers")
+	defer span.End()
+
+	if _, err := l.getTarget(ctx, targetType, targetID); err != nil {
+		return pagination.Page[model.LikeUser]{}, err
+	}
+	return l.repoLike.GetLikers(ctx, targetType, targetID, params)
+}
+
+type likeTarget struct {
+	ownerID uint64
+	photoID uint64
+}
+
+// getTarget looks up who owns the liked photo or comment and which photo
+// it belongs to.
+func (l *likeServiceImpl) getTarget(ctx context.Context, targetType string, targetID uint64) (likeTarget, error) {
+	switch targetType {
+	case model.TargetPhoto:
+		photo, err := l.repoPhoto.GetPhotoByID(ctx, targetID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return likeTarget{}, ErrLikeTargetNotFound
+		}
+		if err != nil {
+			return likeTarget{}, err
+		}
+		return likeTarget{ownerID: photo.UserID, photoID: photo.ID}, nil
+	case model.TargetComment:
+		comment, err := l.repoComment.GetCommentByID1(ctx, targetID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return likeTarget{}, ErrLikeTargetNotFound
+		}
+		if err != nil {
+			return likeTarget{}, err
+		}
+		return likeTarget{ownerID: comment.UserID, photoID: comment.PhotoID}, nil
+	}
+	return likeTarget{}, ErrLikeTargetNotFound
+}
+
+// fillLikes sets the like summary of every item with a single query.
+// target returns the ID of an item and the summary to fill in.
+func fillLikes[T any](ctx context.Context, repoLike repository.LikeQuery, targetType string, viewerID uint64, items []T, target func(*T) (uint64, *model.LikeSummary)) error {
+	ids := make([]uint64, 0, len(items))
+	for i := range items {
+		id, _ := target(&items[i])
+		ids = append(ids, id)
+	}
+	summaries, err := repoLike.GetLikeSummaries(ctx, targetType, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		id, summary := target(&items[i])
+		*summary = summaries[id]
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+)
+
+// fillUsers sets the author of every item, loading all of them with a
+// single query. userID returns the author ID of an item, 0 to skip it, and
+// set embeds the loaded author. Items whose author is gone are left alone.
+func fillUsers[T any](ctx context.Context, repoUser repository.UserQuery, items []T, userID func(*T) uint64, set func(*T, model.User)) error {
+	users, err := repoUser.GetUsersByIDs(ctx, collectIDs(items, userID))
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint64]model.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	for i := range items {
+		if user, ok := byID[userID(&items[i])]; ok {
+			set(&items[i], user)
+		}
+	}
+	return nil
+}
+
+// fillPhotoRefs sets the photo every item points at, loading all of them
+// with a single query. photoID returns the photo ID of an item, 0 to skip
+// it, and set embeds the loaded photo. Items whose photo is gone are left
+// alone.
+func fillPhotoRefs[T any](ctx context.Context, repoPhoto repository.PhotoQuery, items []T, photoID func(*T) uint64, set func(*T, model.UpdatePhoto)) error {
+	photos, err := repoPhoto.GetPhotosByIDs(ctx, collectIDs(items, photoID))
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint64]model.UpdatePhoto, len(photos))
+	for _, photo := range photos {
+		byID[photo.ID] = photo
+	}
+	for i := range items {
+		if photo, ok := byID[photoID(&items[i])]; ok {
+			set(&items[i], photo)
+		}
+	}
+	return nil
+}
+
+// collectIDs returns the distinct non-zero IDs of items.
+func collectIDs[T any](items []T, id func(*T) uint64) []uint64 {
+	seen := map[uint64]bool{}
+	ids := []uint64{}
+	for i := range items {
+		if v := id(&items[i]); v != 0 && !seen[v] {
+			seen[v] = true
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
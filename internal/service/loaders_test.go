@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/pkg/pagination"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// mockPostgres serves both connections from one sqlmock backed gorm.DB.
+type mockPostgres struct {
+	db *gorm.DB
+}
+
+func (m mockPostgres) GetConnection() *gorm.DB        { return m.db }
+func (m mockPostgres) GetReadConnection() *gorm.DB    { return m.db }
+func (m mockPostgres) Ping(ctx context.Context) error { return nil }
+func (m mockPostgres) Close() error                   { return nil }
+
+// countingDB opens a gorm.DB whose first statement lists rows rows and
+// every later one finds nothing. The returned counter is incremented
+// for every statement run.
+func countingDB(t testing.TB, rows int) (mockPostgres, *atomic.Int64) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(string, string) error {
+		return nil
+	})))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	listing := sqlmock.NewRows([]string{"id", "user_id", "photo_id", "title", "caption", "message", "name", "created_at"})
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= rows; i++ {
+		// every row has its own author and photo, the worst case for N+1
+		listing.AddRow(i, i, i, "title", "caption #tag @user", "message #tag @user", "name", createdAt.Add(-time.Duration(i)*time.Second))
+	}
+	mock.ExpectQuery("").WillReturnRows(listing)
+	// enough empty results for a loader querying row by row, so such a
+	// regression fails on the count rather than on the mock
+	for i := 0; i < 10*rows+10; i++ {
+		mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	statements := &atomic.Int64{}
+	count := func(*gorm.DB) { statements.Add(1) }
+	if err := db.Callback().Query().After("gorm:query").Register("test:count_query", count); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Row().After("gorm:row").Register("test:count_row", count); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Raw().After("gorm:raw").Register("test:count_raw", count); err != nil {
+		t.Fatal(err)
+	}
+	return mockPostgres{db: db}, statements
+}
+
+// listStatements runs list over a page of rows rows and returns the number
+// of statements it took.
+func listStatements(t testing.TB, rows int, list func(context.Context, mockPostgres, pagination.Params) (int, error)) int64 {
+	t.Helper()
+	db, statements := countingDB(t, rows)
+	n, err := list(context.Background(), db, pagination.Params{Limit: rows})
+	if err != nil {
+		t.Fatalf("listing %d rows: %v", rows, err)
+	}
+	if n != rows {
+		t.Fatalf("listed %d rows, want %d", n, rows)
+	}
+	return statements.Load()
+}
+
+var listings = []struct {
+	name string
+	list func(context.Context, mockPostgres, pagination.Params) (int, error)
+}{
+	{"GetPhotos", func(ctx context.Context, db mockPostgres, params pagination.Params) (int, error) {
+		svc := NewPhotoService(repository.NewPhotoQuery(db), repository.NewUserQuery(db), repository.NewLikeQuery(db), repository.NewEntityQuery(db), nil, nil, nil)
+		page, err := svc.GetPhotos(ctx, 1, params)
+		return len(page.Data), err
+	}},
+	{"GetComments", func(ctx context.Context, db mockPostgres, params pagination.Params) (int, error) {
+		svc := NewCommentService(repository.NewCommentQuery(db), repository.NewUserQuery(db), repository.NewPhotoQuery(db), repository.NewLikeQuery(db), repository.NewEntityQuery(db), nil)
+		page, err := svc.GetComments(ctx, 1, params)
+		return len(page.Data), err
+	}},
+	{"GetCommentsByPhotoID", func(ctx context.Context, db mockPostgres, params pagination.Params) (int, error) {
+		svc := NewCommentService(repository.NewCommentQuery(db), repository.NewUserQuery(db), repository.NewPhotoQuery(db), repository.NewLikeQuery(db), repository.NewEntityQuery(db), nil)
+		page, err := svc.GetCommentsByPhotoID(ctx, 1, 1, params)
+		return len(page.Data), err
+	}},
+	{"GetSocialMedias", func(ctx context.Context, db mockPostgres, params pagination.Params) (int, error) {
+		svc := NewSocialMediaService(repository.NewSocialMediaQuery(db), repository.NewUserQuery(db))
+		page, err := svc.GetSocialMedias(ctx, params)
+		return len(page.Data), err
+	}},
+}
+
+func TestListingQueryCountIsConstant(t *testing.T) {
+	for _, tt := range listings {
+		t.Run(tt.name, func(t *testing.T) {
+			one := listStatements(t, 1, tt.list)
+			hundred := listStatements(t, 100, tt.list)
+			if one != hundred {
+				t.Errorf("%d statements for 1 row, %d for 100 rows", one, hundred)
+			}
+			if one < 2 {
+				t.Errorf("%d statements, want the listing and its loaders", one)
+			}
+		})
+	}
+}
+
+func BenchmarkListingQueries(b *testing.B) {
+	for _, tt := range listings {
+		b.Run(tt.name, func(b *testing.B) {
+			var statements int64
+			for i := 0; i < b.N; i++ {
+				statements = listStatements(b, pagination.MAX_LIMIT, tt.list)
+			}
+			b.ReportMetric(float64(statements), "queries/op")
+		})
+	}
+}
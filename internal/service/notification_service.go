@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/entity"
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+type NotificationService interface {
+	GetNotifications(ctx context.Context, userID uint64, params pagination.Params) (model.NotificationPage, error)
+	GetNotificationByID(ctx context.Context, id uint64) (model.NotificationView, error)
+	MarkRead(ctx context.Context, userID uint64, ids []uint64) error
+	HandleEvent(ctx context.Context, e event.Event) error
+}
+
+// notificationTypes maps the events that notify someone to the kind of
+// notification they produce.
+var notificationTypes = map[string]string{
+	event.TYPE_COMMENT_CREATED: model.NotificationComment,
+	event.TYPE_REPLY_CREATED:   model.NotificationReply,
+	event.TYPE_LIKE_CREATED:    model.NotificationLike,
+	event.TYPE_FOLLOW_CREATED:  model.NotificationFollow,
+	event.TYPE_MENTION_CREATED: model.NotificationMention,
+}
+
+type notificationServiceImpl struct {
+	repoNotification repository.NotificationQuery
+	bus              *event.Bus
+}
+
+func NewNotificationService(repoNotification repository.NotificationQuery, bus *event.Bus) NotificationService {
+	return &notificationServiceImpl{
+		repoNotification: repoNotification,
+		bus:              bus,
+	}
+}
+
+func (n *notificationServiceImpl) GetNotifications(ctx context.Context, userID uint64, params pagination.Params) (model.NotificationPage, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.GetNotifications")
+	defer span.End()
+
+	page, err := n.repoNotification.GetNotifications(ctx, userID, params)
+	if err != nil {
+		return model.NotificationPage{}, err
+	}
+	unread, err := n.repoNotification.CountUnread(ctx, userID)
+	if err != nil {
+		return model.NotificationPage{}, err
+	}
+	return model.NotificationPage{Page: page, UnreadCount: unread}, nil
+}
+
+func (n *notificationServiceImpl) GetNotificationByID(ctx context.Context, id uint64) (model.NotificationView, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.GetNotificationByID")
+	defer span.End()
+
+	return n.repoNotification.GetNotificationByID(ctx, id)
+}
+
+func (n *notificationServiceImpl) MarkRead(ctx context.Context, userID uint64, ids []uint64) error {
+	ctx, span := tracing.Start(ctx, "NotificationService.MarkRead")
+	defer span.End()
+
+	return n.repoNotification.MarkRead(ctx, userID, ids)
+}
+
+// HandleEvent is the event bus subscriber storing notifications. Nobody is
+// notified of their own actions.
+func (n *notificationServiceImpl) HandleEvent(ctx context.Context, e event.Event) error {
+	ctx, span := tracing.Start(ctx, "NotificationService.HandleEvent")
+	defer span.End()
+
+	kind, ok := notificationTypes[e.Type]
+	if !ok || e.RecipientID == 0 || e.RecipientID == e.ActorID {
+		return nil
+	}
+	notification, err := n.repoNotification.CreateNotification(ctx, model.Notification{
+		UserID:     e.RecipientID,
+		ActorID:    e.ActorID,
+		Type:       kind,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		PhotoID:    e.PhotoID,
+		CreatedAt:  e.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	n.bus.Publish(event.Event{
+		Type:        event.TYPE_NOTIFICATION_CREATED,
+		ActorID:     e.ActorID,
+		RecipientID: e.RecipientID,
+		TargetID:    notification.ID,
+		PhotoID:     e.PhotoID,
+	})
+	return nil
+}
+
+// publishMentions publishes an event for every user mentioned in entities,
+// skipping the users in notified, who already got an event for the same
+// action.
+func publishMentions(bus *event.Bus, actorID uint64, targetType string, targetID uint64, photoID uint64, entities []entity.Entity, notified map[uint64]bool) {
+	for _, e := range entities {
+		if e.Type != entity.TYPE_MENTION || notified[e.UserID] {
+			continue
+		}
+		notified[e.UserID] = true
+		bus.Publish(event.Event{
+			Type:        event.TYPE_MENTION_CREATED,
+			ActorID:     actorID,
+			RecipientID: e.UserID,
+			TargetType:  targetType,
+			TargetID:    targetID,
+			PhotoID:     photoID,
+		})
+	}
+}
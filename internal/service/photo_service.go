@@ -1,70 +1,191 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
 
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/imaging"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/storage"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/entity"
+	"github.com/geedotrar/mygram/pkg/helper"
+	"github.com/geedotrar/mygram/pkg/logger"
+	"github.com/geedotrar/mygram/pkg/pagination"
 )
 
+var (
+	ErrPhotoNotFound        = apperror.NotFound("photo_not_found", "photo not found")
+	ErrPhotoImageNotFound   = apperror.NotFound("photo_image_not_found", "photo image not found")
+	ErrUnsupportedImageType = apperror.Unsupported("unsupported_image_type", "only jpeg, png, gif and webp images are supported")
+	ErrInvalidImage         = apperror.Field("photo", "image is corrupt or too large")
+)
+
+// imageExtensions lists the sniffed content types accepted for uploads.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
 type PhotoService interface {
-	GetPhotos(ctx context.Context) ([]model.Photo, error)
-	GetPhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error)
-	GetPhotoByUserID(ctx context.Context, photoID uint64) ([]model.GetPhoto, error)
+	GetPhotos(ctx context.Context, viewerID uint64, params pagination.Params) (pagination.Page[model.Photo], error)
+	GetPhotoByID(ctx context.Context, id uint64, viewerID uint64) (model.UpdatePhoto, error)
+	GetFeed(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.FeedPhoto], error)
+	GetPhotoByUserID(ctx context.Context, userID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error)
+	GetPhotosByTag(ctx context.Context, tag string, viewerID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error)
 	CreatePhoto(ctx context.Context, photo model.CreatePhoto, userID uint64) (model.CreatePhoto, error)
+	UploadPhoto(ctx context.Context, photo model.CreatePhoto, file io.Reader, userID uint64) (model.CreatePhoto, error)
+	GetPhotoImage(ctx context.Context, id uint64, variant string) (model.UpdatePhoto, storage.Object, error)
+	ProcessPhotoVariants(ctx context.Context, id uint64) error
+	EnqueuePendingPhotos(ctx context.Context) error
 	UpdatePhoto(ctx context.Context, id uint64, photo model.UpdatePhoto) (model.UpdatePhoto, error)
 	DeletePhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error)
 }
 
 type photoServiceImpl struct {
-	repoPhoto repository.PhotoQuery
-	repoUser  repository.UserQuery
+	repoPhoto  repository.PhotoQuery
+	repoUser   repository.UserQuery
+	repoLike   repository.LikeQuery
+	repoEntity repository.EntityQuery
+	store      storage.Storage
+	pool       *imaging.Pool
+	bus        *event.Bus
 }
 
-func NewPhotoService(repoPhoto repository.PhotoQuery, repoUser repository.UserQuery) PhotoService {
+func NewPhotoService(repoPhoto repository.PhotoQuery, repoUser repository.UserQuery, repoLike repository.LikeQuery, repoEntity repository.EntityQuery, store storage.Storage, pool *imaging.Pool, bus *event.Bus) PhotoService {
 	return &photoServiceImpl{
-		repoPhoto: repoPhoto,
-		repoUser:  repoUser,
+		repoPhoto:  repoPhoto,
+		repoUser:   repoUser,
+		repoLike:   repoLike,
+		repoEntity: repoEntity,
+		store:      store,
+		pool:       pool,
+		bus:        bus,
 	}
 }
 
-func (p *photoServiceImpl) GetPhotos(ctx context.Context) ([]model.Photo, error) {
-	photos, err := p.repoPhoto.GetPhotos(ctx)
+func (p *photoServiceImpl) GetPhotos(ctx context.Context, viewerID uint64, params pagination.Params) (pagination.Page[model.Photo], error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetPhotos")
+	defer span.End()
+
+	page, err := p.repoPhoto.GetPhotos(ctx, params)
 	if err != nil {
-		return []model.Photo{}, err
+		return pagination.Page[model.Photo]{}, err
 	}
 
+	photos := page.Data
 	for i, photo := range photos {
-		user, err := p.repoUser.GetUsersByID(ctx, photo.UserID)
+		photos[i].Variants = variantURLs(photo.ID, photo.VariantStatus)
+	}
+	if err := fillUsers(ctx, p.repoUser, photos, func(photo *model.Photo) uint64 {
+		return photo.UserID
+	}, func(photo *model.Photo, user model.User) {
+		photo.User.ID = user.ID
+		photo.User.Email = user.Email
+		photo.User.Username = user.Username
+	}); err != nil {
+		return pagination.Page[model.Photo]{}, err
+	}
+	if err := fillLikes(ctx, p.repoLike, model.TargetPhoto, viewerID, photos, func(photo *model.Photo) (uint64, *model.LikeSummary) {
+		return photo.ID, &photo.LikeSummary
+	}); err != nil {
+		return pagination.Page[model.Photo]{}, err
+	}
+	if err := fillEntities(ctx, p.repoEntity, model.TargetPhoto, photos, func(photo *model.Photo) (uint64, string, *[]entity.Entity) {
+		return photo.ID, photo.Caption, &photo.Entities
+	}); err != nil {
+		return pagination.Page[model.Photo]{}, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	return page, nil
+}
 
-		photos[i].User.ID = user.ID
-		photos[i].User.Email = user.Email
-		photos[i].User.Username = user.Username
-	}
+func (p *photoServiceImpl) GetFeed(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.FeedPhoto], error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetFeed")
+	defer span.End()
 
-	return photos, nil
+	page, err := p.repoPhoto.GetFeed(ctx, userID, params)
+	if err != nil {
+		return pagination.Page[model.FeedPhoto]{}, err
+	}
+	for i := range page.Data {
+		page.Data[i].Variants = variantURLs(page.Data[i].ID, page.Data[i].VariantStatus)
+	}
+	if err := fillLikes(ctx, p.repoLike, model.TargetPhoto, userID, page.Data, func(photo *model.FeedPhoto) (uint64, *model.LikeSummary) {
+		return photo.ID, &photo.LikeSummary
+	}); err != nil {
+		return pagination.Page[model.FeedPhoto]{}, err
+	}
+	if err := fillEntities(ctx, p.repoEntity, model.TargetPhoto, page.Data, func(photo *model.FeedPhoto) (uint64, string, *[]entity.Entity) {
+		return photo.ID, photo.Caption, &photo.Entities
+	}); err != nil {
+		return pagination.Page[model.FeedPhoto]{}, err
+	}
+	return page, nil
 }
 
-func (p *photoServiceImpl) GetPhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error) {
+func (p *photoServiceImpl) GetPhotoByID(ctx context.Context, id uint64, viewerID uint64) (model.UpdatePhoto, error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetPhotoByID")
+	defer span.End()
+
 	photo, err := p.repoPhoto.GetPhotoByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdatePhoto{}, ErrPhotoNotFound
+	}
+	if err != nil {
+		return model.UpdatePhoto{}, err
+	}
+	photo.Variants = variantURLs(photo.ID, photo.VariantStatus)
+	summaries, err := p.repoLike.GetLikeSummaries(ctx, model.TargetPhoto, []uint64{photo.ID}, viewerID)
 	if err != nil {
 		return model.UpdatePhoto{}, err
 	}
-	return photo, err
+	photo.LikeSummary = summaries[photo.ID]
+	photos := []model.UpdatePhoto{photo}
+	if err := fillEntities(ctx, p.repoEntity, model.TargetPhoto, photos, func(photo *model.UpdatePhoto) (uint64, string, *[]entity.Entity) {
+		return photo.ID, photo.Caption, &photo.Entities
+	}); err != nil {
+		return model.UpdatePhoto{}, err
+	}
+	return photos[0], nil
 }
 
 func (p *photoServiceImpl) DeletePhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.DeletePhotoByID")
+	defer span.End()
+
 	photo, err := p.repoPhoto.GetPhotoByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdatePhoto{}, ErrPhotoNotFound
+	}
 	if err != nil {
 		return model.UpdatePhoto{}, err
 	}
 
-	if photo.ID == 0 {
-		return model.UpdatePhoto{}, nil
+	// the variant rows cascade with the photo, so list their files first
+	keys := []string{}
+	if photo.StorageKey != "" {
+		keys = append(keys, photo.StorageKey)
+		variants, err := p.repoPhoto.GetPhotoVariants(ctx, id)
+		if err != nil {
+			return model.UpdatePhoto{}, err
+		}
+		for _, variant := range variants {
+			keys = append(keys, variant.StorageKey)
+		}
 	}
 
 	err = p.repoPhoto.DeletePhotoByID(ctx, id)
@@ -72,10 +193,20 @@ func (p *photoServiceImpl) DeletePhotoByID(ctx context.Context, id uint64) (mode
 		return model.UpdatePhoto{}, err
 	}
 
-	return photo, err
+	// the row is gone, a leftover file is only wasted space
+	for _, key := range keys {
+		if err := p.store.Delete(ctx, key); err != nil {
+			logger.FromContext(ctx).Error("cannot delete photo file", "key", key, "error", err)
+		}
+	}
+
+	return photo, nil
 }
 
 func (p *photoServiceImpl) CreatePhoto(ctx context.Context, CreatePhoto model.CreatePhoto, userID uint64) (model.CreatePhoto, error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.CreatePhoto")
+	defer span.End()
+
 	photo := model.CreatePhoto{
 		Title:    CreatePhoto.Title,
 		Caption:  CreatePhoto.Caption,
@@ -83,25 +214,305 @@ func (p *photoServiceImpl) CreatePhoto(ctx context.Context, CreatePhoto model.Cr
 		UserID:   userID,
 	}
 
-	createdPhoto, err := p.repoPhoto.CreatePhoto(ctx, photo)
+	entities, err := resolveEntities(ctx, p.repoUser, photo.Caption)
+	if err != nil {
+		return model.CreatePhoto{}, err
+	}
+	tags, userIDs := entityLinks(entities)
+	createdPhoto, err := p.repoPhoto.CreatePhoto(ctx, photo, tags, userIDs)
+	if err != nil {
+		return model.CreatePhoto{}, err
+	}
+	createdPhoto.Entities = entities
+	publishMentions(p.bus, userID, model.TargetPhoto, createdPhoto.ID, createdPhoto.ID, createdPhoto.Entities, map[uint64]bool{})
+	return createdPhoto, nil
+}
+
+// UploadPhoto stores an uploaded image and creates a photo pointing at it.
+// The caller is expected to have bounded the size of file.
+func (p *photoServiceImpl) UploadPhoto(ctx context.Context, CreatePhoto model.CreatePhoto, file io.Reader, userID uint64) (model.CreatePhoto, error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.UploadPhoto")
+	defer span.End()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return model.CreatePhoto{}, err
+	}
+
+	// trust the bytes, not the client supplied content type
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return model.CreatePhoto{}, ErrUnsupportedImageType
+	}
+	if err := imaging.CheckDimensions(data); err != nil {
+		return model.CreatePhoto{}, ErrInvalidImage
+	}
+	// never keep the uploader's camera details or GPS position
+	data, err = imaging.StripMetadata(data, contentType)
+	if err != nil {
+		return model.CreatePhoto{}, ErrInvalidImage
+	}
+
+	name, err := helper.GenerateRandomID(ctx)
 	if err != nil {
 		return model.CreatePhoto{}, err
 	}
+	key := fmt.Sprintf("photos/%d/%s%s", userID, name, ext)
+	sum := sha256.Sum256(data)
+	entities, err := resolveEntities(ctx, p.repoUser, CreatePhoto.Caption)
+	if err != nil {
+		return model.CreatePhoto{}, err
+	}
+	tags, userIDs := entityLinks(entities)
+
+	if err := p.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return model.CreatePhoto{}, err
+	}
+
+	photo := model.CreatePhoto{
+		Title:         CreatePhoto.Title,
+		Caption:       CreatePhoto.Caption,
+		UserID:        userID,
+		VariantStatus: model.VariantStatusPending,
+		PhotoFile: model.PhotoFile{
+			StorageKey:  key,
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			Checksum:    hex.EncodeToString(sum[:]),
+		},
+	}
+	createdPhoto, err := p.repoPhoto.CreatePhoto(ctx, photo, tags, userIDs)
+	if err != nil {
+		if err := p.store.Delete(ctx, key); err != nil {
+			logger.FromContext(ctx).Error("cannot delete orphan photo file", "key", key, "error", err)
+		}
+		return model.CreatePhoto{}, err
+	}
+	createdPhoto.Entities = entities
+	publishMentions(p.bus, userID, model.TargetPhoto, createdPhoto.ID, createdPhoto.ID, createdPhoto.Entities, map[uint64]bool{})
+
+	// a full queue is fine, pending photos are picked up again on startup
+	if !p.pool.Enqueue(createdPhoto.ID) {
+		logger.FromContext(ctx).Warn("photo processing queue is full, photo stays pending", "photo_id", createdPhoto.ID)
+	}
 	return createdPhoto, nil
 }
 
+// GetPhotoImage opens the stored image of a photo, or of one of its
+// variants when variant is set; the returned photo then describes the
+// variant file. A photo without a stored file is not found: its URL was
+// typed in by the owner and is never redirected to. Callers must close
+// the Body.
+func (p *photoServiceImpl) GetPhotoImage(ctx context.Context, id uint64, variant string) (model.UpdatePhoto, storage.Object, error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetPhotoImage")
+	defer span.End()
+
+	photo, err := p.repoPhoto.GetPhotoByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdatePhoto{}, storage.Object{}, ErrPhotoNotFound
+	}
+	if err != nil {
+		return model.UpdatePhoto{}, storage.Object{}, err
+	}
+	if photo.StorageKey == "" {
+		return model.UpdatePhoto{}, storage.Object{}, ErrPhotoNotFound
+	}
+
+	if variant != "" {
+		variants, err := p.repoPhoto.GetPhotoVariants(ctx, id)
+		if err != nil {
+			return model.UpdatePhoto{}, storage.Object{}, err
+		}
+		found := false
+		for _, v := range variants {
+			if v.Name == variant {
+				photo.PhotoFile = v.PhotoFile
+				found = true
+			}
+		}
+		if !found {
+			return model.UpdatePhoto{}, storage.Object{}, ErrPhotoImageNotFound
+		}
+	}
+
+	object, err := p.store.Get(ctx, photo.StorageKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return model.UpdatePhoto{}, storage.Object{}, ErrPhotoImageNotFound
+	}
+	if err != nil {
+		return model.UpdatePhoto{}, storage.Object{}, err
+	}
+	return photo, object, nil
+}
+
+// ProcessPhotoVariants renders and stores the resized variants of a photo.
+// It runs on the imaging pool, outside of any request.
+func (p *photoServiceImpl) ProcessPhotoVariants(ctx context.Context, id uint64) error {
+	ctx, span := tracing.Start(ctx, "PhotoService.ProcessPhotoVariants")
+	defer span.End()
+
+	photo, err := p.repoPhoto.GetPhotoByID(ctx, id)
+	// the photo may have been deleted while it was queued
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if photo.StorageKey == "" {
+		return nil
+	}
+
+	err = p.processPhotoVariants(ctx, photo)
+	if err != nil {
+		if err := p.repoPhoto.UpdateVariantStatus(ctx, id, model.VariantStatusFailed); err != nil {
+			logger.FromContext(ctx).Error("cannot update variant status", "photo_id", id, "error", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *photoServiceImpl) processPhotoVariants(ctx context.Context, photo model.UpdatePhoto) error {
+	object, err := p.store.Get(ctx, photo.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(object.Body)
+	object.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	variants, err := imaging.GenerateVariants(data, photo.ContentType, imaging.DefaultVariants)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(photo.StorageKey, path.Ext(photo.StorageKey))
+	photoVariants := []model.PhotoVariant{}
+	for _, variant := range variants {
+		key := fmt.Sprintf("%s_%s%s", base, variant.Name, imageExtensions[variant.ContentType])
+		if err := p.store.Put(ctx, key, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(variant.Data)
+		photoVariants = append(photoVariants, model.PhotoVariant{
+			PhotoID: photo.ID,
+			Name:    variant.Name,
+			Width:   variant.Width,
+			Height:  variant.Height,
+			PhotoFile: model.PhotoFile{
+				StorageKey:  key,
+				ContentType: variant.ContentType,
+				Size:        int64(len(variant.Data)),
+				Checksum:    hex.EncodeToString(sum[:]),
+			},
+		})
+	}
+	return p.repoPhoto.SavePhotoVariants(ctx, photo.ID, photoVariants)
+}
+
+// EnqueuePendingPhotos requeues photos whose variants were never produced,
+// for instance because the process stopped while they were queued.
+func (p *photoServiceImpl) EnqueuePendingPhotos(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "PhotoService.EnqueuePendingPhotos")
+	defer span.End()
+
+	ids, err := p.repoPhoto.GetPendingPhotoIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !p.pool.Enqueue(id) {
+			logger.FromContext(ctx).Warn("photo processing queue is full, photo stays pending", "photo_id", id)
+			break
+		}
+	}
+	return nil
+}
+
 func (p *photoServiceImpl) UpdatePhoto(ctx context.Context, id uint64, photo model.UpdatePhoto) (model.UpdatePhoto, error) {
-	updatedPhoto, err := p.repoPhoto.UpdatePhoto(ctx, id, photo)
+	ctx, span := tracing.Start(ctx, "PhotoService.UpdatePhoto")
+	defer span.End()
+
+	// processing state is owned by the imaging pool
+	photo.VariantStatus = ""
+
+	entities, err := resolveEntities(ctx, p.repoUser, photo.Caption)
+	if err != nil {
+		return model.UpdatePhoto{}, err
+	}
+	tags, userIDs := entityLinks(entities)
+	notified, err := mentionedUsers(ctx, p.repoEntity, model.TargetPhoto, id)
+	if err != nil {
+		return model.UpdatePhoto{}, err
+	}
+	updatedPhoto, err := p.repoPhoto.UpdatePhoto(ctx, id, photo, tags, userIDs)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdatePhoto{}, ErrPhotoNotFound
+	}
 	if err != nil {
 		return model.UpdatePhoto{}, err
 	}
+	updatedPhoto.Entities = entities
+	// only the owner edits a photo
+	publishMentions(p.bus, updatedPhoto.UserID, model.TargetPhoto, id, id, updatedPhoto.Entities, notified)
 	return updatedPhoto, nil
 }
 
-func (p *photoServiceImpl) GetPhotoByUserID(ctx context.Context, photoID uint64) ([]model.GetPhoto, error) {
-	photo, err := p.repoPhoto.GetPhotoByUserID(ctx, photoID)
+func (p *photoServiceImpl) GetPhotoByUserID(ctx context.Context, userID uint64, viewerID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetPhotoByUserID")
+	defer span.End()
+
+	page, err := p.repoPhoto.GetPhotoByUserID(ctx, userID, params)
 	if err != nil {
-		return []model.GetPhoto{}, err
+		return pagination.Page[model.GetPhoto]{}, err
 	}
-	return photo, nil
+	if err := p.fillPhotos(ctx, viewerID, page.Data); err != nil {
+		return pagination.Page[model.GetPhoto]{}, err
+	}
+	return page, nil
+}
+
+func (p *photoServiceImpl) GetPhotosByTag(ctx context.Context, tag string, viewerID uint64, params pagination.Params) (pagination.Page[model.GetPhoto], error) {
+	ctx, span := tracing.Start(ctx, "PhotoService.GetPhotosByTag")
+	defer span.End()
+
+	page, err := p.repoPhoto.GetPhotosByTag(ctx, entity.NormalizeTag(tag), params)
+	if err != nil {
+		return pagination.Page[model.GetPhoto]{}, err
+	}
+	if err := p.fillPhotos(ctx, viewerID, page.Data); err != nil {
+		return pagination.Page[model.GetPhoto]{}, err
+	}
+	return page, nil
+}
+
+// fillPhotos sets the variants, likes and entities of listed photos.
+func (p *photoServiceImpl) fillPhotos(ctx context.Context, viewerID uint64, photos []model.GetPhoto) error {
+	for i := range photos {
+		photos[i].Variants = variantURLs(photos[i].ID, photos[i].VariantStatus)
+	}
+	if err := fillLikes(ctx, p.repoLike, model.TargetPhoto, viewerID, photos, func(photo *model.GetPhoto) (uint64, *model.LikeSummary) {
+		return photo.ID, &photo.LikeSummary
+	}); err != nil {
+		return err
+	}
+	return fillEntities(ctx, p.repoEntity, model.TargetPhoto, photos, func(photo *model.GetPhoto) (uint64, string, *[]entity.Entity) {
+		return photo.ID, photo.Caption, &photo.Entities
+	})
+}
+
+// variantURLs lists the variant endpoints of a photo once they are ready.
+func variantURLs(id uint64, status string) map[string]string {
+	if status != model.VariantStatusReady {
+		return nil
+	}
+	urls := map[string]string{}
+	for _, spec := range imaging.DefaultVariants {
+		urls[spec.Name] = model.PhotoImageURL(id) + "/" + spec.Name
+	}
+	return urls
 }
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/storage"
+)
+
+// cascadePhotoQuery stores one photo and its variants, dropping the
+// variants with the photo like the ON DELETE CASCADE of photo_variants.
+type cascadePhotoQuery struct {
+	repository.PhotoQuery
+	photo    *model.UpdatePhoto
+	variants []model.PhotoVariant
+}
+
+func (q *cascadePhotoQuery) GetPhotoByID(ctx context.Context, id uint64) (model.UpdatePhoto, error) {
+	if q.photo == nil || q.photo.ID != id {
+		return model.UpdatePhoto{}, apperror.ErrNotFound
+	}
+	return *q.photo, nil
+}
+
+func (q *cascadePhotoQuery) GetPhotoVariants(ctx context.Context, photoID uint64) ([]model.PhotoVariant, error) {
+	if q.photo == nil || q.photo.ID != photoID {
+		return nil, nil
+	}
+	return q.variants, nil
+}
+
+func (q *cascadePhotoQuery) DeletePhotoByID(ctx context.Context, id uint64) error {
+	q.photo = nil
+	q.variants = nil
+	return nil
+}
+
+func (q *cascadePhotoQuery) UpdatePhoto(ctx context.Context, id uint64, photo model.UpdatePhoto, tags []string, userIDs []uint64) (model.UpdatePhoto, error) {
+	if q.photo == nil || q.photo.ID != id {
+		return model.UpdatePhoto{}, apperror.ErrNotFound
+	}
+	q.photo.Caption = photo.Caption
+	return *q.photo, nil
+}
+
+// recordingStorage records the deleted keys.
+type recordingStorage struct {
+	deleted []string
+}
+
+func (s *recordingStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	return nil
+}
+
+func (s *recordingStorage) Get(ctx context.Context, key string) (storage.Object, error) {
+	return storage.Object{}, storage.ErrObjectNotFound
+}
+
+func (s *recordingStorage) Delete(ctx context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func TestDeletePhotoByIDDeletesVariantFiles(t *testing.T) {
+	repo := &cascadePhotoQuery{
+		photo: &model.UpdatePhoto{ID: 7, PhotoFile: model.PhotoFile{StorageKey: "photos/1/a.jpg"}},
+		variants: []model.PhotoVariant{
+			{Name: "large", PhotoFile: model.PhotoFile{StorageKey: "photos/1/a_large.jpg"}},
+			{Name: "medium", PhotoFile: model.PhotoFile{StorageKey: "photos/1/a_medium.jpg"}},
+			{Name: "thumbnail", PhotoFile: model.PhotoFile{StorageKey: "photos/1/a_thumbnail.jpg"}},
+		},
+	}
+	store := &recordingStorage{}
+	svc := NewPhotoService(repo, nil, nil, nil, store, nil, nil)
+
+	if _, err := svc.DeletePhotoByID(context.Background(), 7); err != nil {
+		t.Fatalf("DeletePhotoByID: %v", err)
+	}
+
+	want := []string{"photos/1/a.jpg", "photos/1/a_large.jpg", "photos/1/a_medium.jpg", "photos/1/a_thumbnail.jpg"}
+	if len(store.deleted) != len(want) {
+		t.Fatalf("got %d store.Delete calls, want %d: %v", len(store.deleted), len(want), store.deleted)
+	}
+	slices.Sort(store.deleted)
+	if !slices.Equal(store.deleted, want) {
+		t.Errorf("deleted %v, want %v", store.deleted, want)
+	}
+}
+
+func TestDeletePhotoByIDWithoutFile(t *testing.T) {
+	repo := &cascadePhotoQuery{photo: &model.UpdatePhoto{ID: 7, PhotoURL: "https://example.com/a.jpg"}}
+	store := &recordingStorage{}
+	svc := NewPhotoService(repo, nil, nil, nil, store, nil, nil)
+
+	if _, err := svc.DeletePhotoByID(context.Background(), 7); err != nil {
+		t.Fatalf("DeletePhotoByID: %v", err)
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("got %d store.Delete calls for a photo hosted elsewhere, want 0", len(store.deleted))
+	}
+}
+
+func TestDeletePhotoByIDNotFound(t *testing.T) {
+	svc := NewPhotoService(&cascadePhotoQuery{}, nil, nil, nil, &recordingStorage{}, nil, nil)
+
+	_, err := svc.DeletePhotoByID(context.Background(), 7)
+	if err != ErrPhotoNotFound {
+		t.Fatalf("got error %v, want ErrPhotoNotFound", err)
+	}
+}
+
+func TestGetPhotoImageWithoutFile(t *testing.T) {
+	repo := &cascadePhotoQuery{photo: &model.UpdatePhoto{ID: 7, PhotoURL: "https://evil.example/phish"}}
+	svc := NewPhotoService(repo, nil, nil, nil, &recordingStorage{}, nil, nil)
+
+	photo, object, err := svc.GetPhotoImage(context.Background(), 7, "")
+	if err != ErrPhotoNotFound {
+		t.Fatalf("got error %v, want ErrPhotoNotFound", err)
+	}
+	if photo.PhotoURL != "" || object.Body != nil {
+		t.Errorf("got photo %+v and object %+v, want neither", photo, object)
+	}
+}
+
+func TestUpdatePhotoMentionsNewUsersOnly(t *testing.T) {
+	repo := &cascadePhotoQuery{photo: &model.UpdatePhoto{ID: 7, UserID: 1, Caption: "with @bob"}}
+	users := &usernameQuery{users: []model.User{{ID: 2, Username: "bob"}, {ID: 3, Username: "carol"}}}
+	entities := &mentionsQuery{mentioned: map[uint64][]model.MentionedUser{7: {{TargetID: 7, UserID: 2, Username: "bob"}}}}
+	bus, events := recordEvents(t)
+	svc := NewPhotoService(repo, users, nil, entities, &recordingStorage{}, nil, bus)
+
+	if _, err := svc.UpdatePhoto(context.Background(), 7, model.UpdatePhoto{Caption: "with @bob and @carol"}); err != nil {
+		t.Fatalf("UpdatePhoto: %v", err)
+	}
+
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("got %d events, want carol's mention only: %+v", len(got), got)
+	}
+	want := event.Event{Type: event.TYPE_MENTION_CREATED, ActorID: 1, RecipientID: 3, TargetType: model.TargetPhoto, TargetID: 7, PhotoID: 7}
+	got[0].CreatedAt = time.Time{}
+	if got[0] != want {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+}
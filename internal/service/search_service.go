@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/entity"
+	"github.com/geedotrar/mygram/pkg/pagination"
+)
+
+// maxSearchLength caps the length of a search query, in characters.
+const maxSearchLength = 200
+
+var (
+	ErrEmptySearch   = apperror.Field("q", "search query cannot be empty")
+	ErrSearchTooLong = apperror.Field("q", "search query is too long")
+)
+
+type SearchService interface {
+	SearchPhotos(ctx context.Context, query string, viewerID uint64, params pagination.Params) (pagination.Page[model.PhotoSearchResult], error)
+	SearchUsers(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.UserSearchResult], error)
+	SearchComments(ctx context.Context, query string, viewerID uint64, params pagination.Params) (pagination.Page[model.CommentSearchResult], error)
+}
+
+type searchServiceImpl struct {
+	repoSearch repository.SearchQuery
+	repoLike   repository.LikeQuery
+	repoEntity repository.EntityQuery
+}
+
+func NewSearchService(repoSearch repository.SearchQuery, repoLike repository.LikeQuery, repoEntity repository.EntityQuery) SearchService {
+	return &searchServiceImpl{
+		repoSearch: repoSearch,
+		repoLike:   repoLike,
+		repoEntity: repoEntity,
+	}
+}
+
+func (s *searchServiceImpl) SearchPhotos(ctx context.Context, query string, viewerID uint64, params pagination.Params) (pagination.Page[model.PhotoSearchResult], error) {
+	ctx, span := tracing.Start(ctx, "SearchService.SearchPhotos")
+	defer span.End()
+
+	query, err := checkSearch(query)
+	if err != nil {
+		return pagination.Page[model.PhotoSearchResult]{}, err
+	}
+	page, err := s.repoSearch.SearchPhotos(ctx, query, params)
+	if err != nil {
+		return pagination.Page[model.PhotoSearchResult]{}, err
+	}
+
+	photos := page.Data
+	for i := range photos {
+		photos[i].Variants = variantURLs(photos[i].ID, photos[i].VariantStatus)
+	}
+	if err := fillLikes(ctx, s.repoLike, model.TargetPhoto, viewerID, photos, func(photo *model.PhotoSearchResult) (uint64, *model.LikeSummary) {
+		return photo.ID, &photo.LikeSummary
+	}); err != nil {
+		return pagination.Page[model.PhotoSearchResult]{}, err
+	}
+	if err := fillEntities(ctx, s.repoEntity, model.TargetPhoto, photos, func(photo *model.PhotoSearchResult) (uint64, string, *[]entity.Entity) {
+		return photo.ID, photo.Caption, &photo.Entities
+	}); err != nil {
+		return pagination.Page[model.PhotoSearchResult]{}, err
+	}
+	return page, nil
+}
+
+// SearchUsers matches usernames starting with the words of query, so
+// results show up while the username is being typed.
+func (s *searchServiceImpl) SearchUsers(ctx context.Context, query string, params pagination.Params) (pagination.Page[model.UserSearchResult], error) {
+	ctx, span := tracing.Start(ctx, "SearchService.SearchUsers")
+	defer span.End()
+
+	query, err := checkSearch(query)
+	if err != nil {
+		return pagination.Page[model.UserSearchResult]{}, err
+	}
+	username := strings.TrimPrefix(query, "@")
+	prefix := prefixQuery(username)
+	if prefix == "" {
+		return pagination.NewPage([]model.UserSearchResult{}, params, nil), nil
+	}
+	return s.repoSearch.SearchUsers(ctx, prefix, username, params)
+}
+
+func (s *searchServiceImpl) SearchComments(ctx context.Context, query string, viewerID uint64, params pagination.Params) (pagination.Page[model.CommentSearchResult], error) {
+	ctx, span := tracing.Start(ctx, "SearchService.SearchComments")
+	defer span.End()
+
+	query, err := checkSearch(query)
+	if err != nil {
+		return pagination.Page[model.CommentSearchResult]{}, err
+	}
+	page, err := s.repoSearch.SearchComments(ctx, query, params)
+	if err != nil {
+		return pagination.Page[model.CommentSearchResult]{}, err
+	}
+
+	comments := page.Data
+	if err := fillLikes(ctx, s.repoLike, model.TargetComment, viewerID, comments, func(comment *model.CommentSearchResult) (uint64, *model.LikeSummary) {
+		return comment.ID, &comment.LikeSummary
+	}); err != nil {
+		return pagination.Page[model.CommentSearchResult]{}, err
+	}
+	if err := fillEntities(ctx, s.repoEntity, model.TargetComment, comments, func(comment *model.CommentSearchResult) (uint64, string, *[]entity.Entity) {
+		return comment.ID, comment.Message, &comment.Entities
+	}); err != nil {
+		return pagination.Page[model.CommentSearchResult]{}, err
+	}
+	return page, nil
+}
+
+func checkSearch(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", ErrEmptySearch
+	}
+	if utf8.RuneCountInString(query) > maxSearchLength {
+		return "", ErrSearchTooLong
+	}
+	return query, nil
+}
+
+// prefixQuery turns the words of query into a tsquery matching words that
+// start with each of them, e.g. "jane do" becomes "jane:* & do:*". Anything
+// but letters and digits separates words, so the result is always valid
+// tsquery syntax.
+func prefixQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
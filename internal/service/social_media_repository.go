@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 
+	"github.com/geedotrar/mygram/internal/apperror"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
+	"github.com/geedotrar/mygram/pkg/pagination"
 )
 
 type SocialMediaService interface {
@@ -12,11 +16,13 @@ type SocialMediaService interface {
 	DeleteSocialMediaByID(ctx context.Context, id uint64) (model.UpdateSocialMedia, error)
 	CreateSocialMedia(ctx context.Context, socialMedia model.CreateSocialMedia, user uint64) (model.CreateSocialMedia, error)
 	UpdateSocialMedia(ctx context.Context, id uint64, socialMedia model.UpdateSocialMedia) (model.UpdateSocialMedia, error)
-	GetSocialMediasByUserID(ctx context.Context, userID uint64) ([]model.SocialMedia, error)
+	GetSocialMediasByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.SocialMedia], error)
 	GetSocialMediaByID1(ctx context.Context, id uint64) (model.UpdateSocialMedia, error)
-	GetSocialMedias(ctx context.Context) ([]model.SocialMedia, error)
+	GetSocialMedias(ctx context.Context, params pagination.Params) (pagination.Page[model.SocialMedia], error)
 }
 
+var ErrSocialMediaNotFound = apperror.NotFound("social_media_not_found", "social media not found")
+
 type socialMediaServiceImpl struct {
 	repoSocialMedia repository.SocialMediaQuery
 	repoUser        repository.UserQuery
@@ -30,12 +36,18 @@ func NewSocialMediaService(repoSocialMedia repository.SocialMediaQuery, repoUser
 }
 
 func (c *socialMediaServiceImpl) GetSocialMediaByID(ctx context.Context, id uint64) (model.SocialMedia, error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.GetSocialMediaByID")
+	defer span.End()
+
 	socialMedia, err := c.repoSocialMedia.GetSocialMediaByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.SocialMedia{}, ErrSocialMediaNotFound
+	}
 	if err != nil {
 		return model.SocialMedia{}, err
 	}
 	user, err := c.repoUser.GetUsersByID(ctx, socialMedia.UserID)
-	if err != nil {
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
 		return model.SocialMedia{}, err
 	}
 
@@ -46,42 +58,43 @@ func (c *socialMediaServiceImpl) GetSocialMediaByID(ctx context.Context, id uint
 	return socialMedia, err
 }
 func (c *socialMediaServiceImpl) GetSocialMediaByID1(ctx context.Context, id uint64) (model.UpdateSocialMedia, error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.GetSocialMediaByID1")
+	defer span.End()
+
 	socialMedia, err := c.repoSocialMedia.GetSocialMediaByID1(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateSocialMedia{}, ErrSocialMediaNotFound
+	}
 	if err != nil {
 		return model.UpdateSocialMedia{}, err
 	}
 	return socialMedia, err
 }
-func (c *socialMediaServiceImpl) GetSocialMedias(ctx context.Context) ([]model.SocialMedia, error) {
-	socialMedias, err := c.repoSocialMedia.GetSocialMedias(ctx)
+func (c *socialMediaServiceImpl) GetSocialMedias(ctx context.Context, params pagination.Params) (pagination.Page[model.SocialMedia], error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.GetSocialMedias")
+	defer span.End()
+
+	page, err := c.repoSocialMedia.GetSocialMedias(ctx, params)
 	if err != nil {
-		return nil, err
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-
-	for i, socialMedia := range socialMedias {
-		user, err := c.repoUser.GetUsersByID(ctx, socialMedia.UserID)
-
-		if err != nil {
-			return nil, err
-		}
-
-		socialMedias[i].User.Email = user.Email
-		socialMedias[i].User.Username = user.Username
-		socialMedias[i].User.ID = user.ID
-
+	if err := c.fillSocialMedias(ctx, page.Data); err != nil {
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-	return socialMedias, nil
+	return page, nil
 }
 func (c *socialMediaServiceImpl) DeleteSocialMediaByID(ctx context.Context, id uint64) (model.UpdateSocialMedia, error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.DeleteSocialMediaByID")
+	defer span.End()
+
 	socialMedia, err := c.repoSocialMedia.GetSocialMediaByID1(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateSocialMedia{}, ErrSocialMediaNotFound
+	}
 	if err != nil {
 		return model.UpdateSocialMedia{}, err
 	}
 
-	if socialMedia.ID == 0 {
-		return model.UpdateSocialMedia{}, nil
-	}
-
 	err = c.repoSocialMedia.DeleteSocialMediaByID(ctx, id)
 	if err != nil {
 		return model.UpdateSocialMedia{}, err
@@ -91,6 +104,9 @@ func (c *socialMediaServiceImpl) DeleteSocialMediaByID(ctx context.Context, id u
 }
 
 func (c *socialMediaServiceImpl) CreateSocialMedia(ctx context.Context, CreateSocialMedia model.CreateSocialMedia, userID uint64) (model.CreateSocialMedia, error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.CreateSocialMedia")
+	defer span.End()
+
 	socialMedia := model.CreateSocialMedia{
 		Name:           CreateSocialMedia.Name,
 		SocialMediaURL: CreateSocialMedia.SocialMediaURL,
@@ -104,29 +120,40 @@ func (c *socialMediaServiceImpl) CreateSocialMedia(ctx context.Context, CreateSo
 }
 
 func (c *socialMediaServiceImpl) UpdateSocialMedia(ctx context.Context, id uint64, socialMedia model.UpdateSocialMedia) (model.UpdateSocialMedia, error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.UpdateSocialMedia")
+	defer span.End()
+
 	updatedSocialMedia, err := c.repoSocialMedia.UpdateSocialMedia(ctx, id, socialMedia)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UpdateSocialMedia{}, ErrSocialMediaNotFound
+	}
 	if err != nil {
 		return model.UpdateSocialMedia{}, err
 	}
 	return updatedSocialMedia, nil
 }
 
-func (c *socialMediaServiceImpl) GetSocialMediasByUserID(ctx context.Context, userID uint64) ([]model.SocialMedia, error) {
-	socialMedias, err := c.repoSocialMedia.GetSocialMediasByUserID(ctx, userID)
+func (c *socialMediaServiceImpl) GetSocialMediasByUserID(ctx context.Context, userID uint64, params pagination.Params) (pagination.Page[model.SocialMedia], error) {
+	ctx, span := tracing.Start(ctx, "SocialMediaService.GetSocialMediasByUserID")
+	defer span.End()
+
+	page, err := c.repoSocialMedia.GetSocialMediasByUserID(ctx, userID, params)
 	if err != nil {
-		return nil, err
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-	for i, socialMedia := range socialMedias {
-		user, err := c.repoUser.GetUsersByID(ctx, socialMedia.UserID)
-
-		if err != nil {
-			return nil, err
-		}
-
-		socialMedias[i].User.Email = user.Email
-		socialMedias[i].User.Username = user.Username
-		socialMedias[i].User.ID = user.ID
-
+	if err := c.fillSocialMedias(ctx, page.Data); err != nil {
+		return pagination.Page[model.SocialMedia]{}, err
 	}
-	return socialMedias, nil
+	return page, nil
+}
+
+// fillSocialMedias embeds the owner of each social media.
+func (c *socialMediaServiceImpl) fillSocialMedias(ctx context.Context, socialMedias []model.SocialMedia) error {
+	return fillUsers(ctx, c.repoUser, socialMedias, func(socialMedia *model.SocialMedia) uint64 {
+		return socialMedia.UserID
+	}, func(socialMedia *model.SocialMedia, user model.User) {
+		socialMedia.User.ID = user.ID
+		socialMedia.User.Email = user.Email
+		socialMedia.User.Username = user.Username
+	})
 }
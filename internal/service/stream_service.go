@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
+	"github.com/geedotrar/mygram/internal/stream"
+	"github.com/geedotrar/mygram/internal/tracing"
+)
+
+type StreamService interface {
+	Subscribe(userID uint64, photoIDs []uint64) *stream.Subscription
+	HandleEvent(ctx context.Context, e event.Event) error
+}
+
+type streamServiceImpl struct {
+	hub                 *stream.Hub
+	commentService      CommentService
+	notificationService NotificationService
+}
+
+func NewStreamService(hub *stream.Hub, commentService CommentService, notificationService NotificationService) StreamService {
+	return &streamServiceImpl{
+		hub:                 hub,
+		commentService:      commentService,
+		notificationService: notificationService,
+	}
+}
+
+// Subscribe streams the notifications of userID and the new comments on
+// photoIDs.
+func (s *streamServiceImpl) Subscribe(userID uint64, photoIDs []uint64) *stream.Subscription {
+	topics := []string{stream.UserTopic(userID)}
+	for _, photoID := range photoIDs {
+		topics = append(topics, stream.PhotoTopic(photoID))
+	}
+	return s.hub.Subscribe(topics...)
+}
+
+// HandleEvent is the event bus subscriber pushing new comments and stored
+// notifications to the streams.
+func (s *streamServiceImpl) HandleEvent(ctx context.Context, e event.Event) error {
+	ctx, span := tracing.Start(ctx, "StreamService.HandleEvent")
+	defer span.End()
+
+	switch e.Type {
+	case event.TYPE_PHOTO_COMMENTED:
+		comment, err := s.commentService.GetCommentByID(ctx, e.TargetID, 0)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.hub.Publish(ctx, stream.PhotoTopic(e.PhotoID), stream.TYPE_COMMENT, comment)
+	case event.TYPE_NOTIFICATION_CREATED:
+		notification, err := s.notificationService.GetNotificationByID(ctx, e.TargetID)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.hub.Publish(ctx, stream.UserTopic(e.RecipientID), stream.TYPE_NOTIFICATION, notification)
+	}
+	return nil
+}
@@ -3,44 +3,102 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/event"
 	"github.com/geedotrar/mygram/internal/model"
 	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/internal/tracing"
 	"github.com/geedotrar/mygram/pkg/helper"
+	"github.com/geedotrar/mygram/pkg/pagination"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
-	GetUsers(ctx context.Context) ([]model.User, error)
+	GetUsers(ctx context.Context, params pagination.Params) (pagination.Page[model.User], error)
 	GetUsersByID(ctx context.Context, id uint64) (model.User, error)
 	DeleteUsersById(ctx context.Context, id uint64) (model.User, error)
 	EditUser(ctx context.Context, id uint64, user model.User) (model.User, error)
+	UpdateUserRole(ctx context.Context, id uint64, role string) (model.User, error)
 
 	SignUp(ctx context.Context, userSignUp model.UserSignUp) (model.UserView, error)
-	GenerateUserAccessToken(ctx context.Context, user model.User) (token string, err error)
+	GenerateUserTokens(ctx context.Context, user model.User) (model.UserToken, error)
+	RefreshUserTokens(ctx context.Context, refreshToken string) (model.UserToken, error)
+	ValidateSession(ctx context.Context, sessionID uint64, jti string) error
+	RevokeSession(ctx context.Context, sessionID uint64) error
 	CheckCredentials(ctx context.Context, email string, password string) (model.User, error)
+
+	GetUserProfile(ctx context.Context, id uint64) (model.UserProfile, error)
+	FollowUser(ctx context.Context, followerID uint64, followingID uint64) error
+	UnfollowUser(ctx context.Context, followerID uint64, followingID uint64) error
+	GetFollowers(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error)
+	GetFollowing(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error)
+	GetMentions(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.MentionView], error)
 }
 
+var (
+	ErrUserNotFound     = apperror.NotFound("user_not_found", "user not found")
+	ErrSelfFollow       = apperror.Validation("self_follow", "you cannot follow yourself")
+	ErrAlreadyFollowing = apperror.Conflict("already_following", "you already follow this user")
+	ErrNotFollowing     = apperror.NotFound("not_following", "you do not follow this user")
+	ErrEmailTaken       = apperror.Conflict("email_taken", "email already exist")
+	ErrInvalidDob       = apperror.Field("dob", "invalid date of birth format")
+	ErrTooYoung         = apperror.Field("dob", "age must be at least 8 years old")
+	ErrInvalidRole      = apperror.Field("role", "invalid role")
+
+	ErrInvalidCredentials = apperror.Unauthorized("invalid_credentials", "invalid email or password")
+	ErrInvalidToken       = apperror.Unauthorized("invalid_token", "invalid token")
+	ErrTokenReused        = apperror.Unauthorized("token_reused", "refresh token has already been used")
+	ErrSessionNotFound    = apperror.Unauthorized("session_not_found", "session not found")
+	ErrSessionRevoked     = apperror.Unauthorized("session_revoked", "session has been revoked")
+	ErrSessionExpired     = apperror.Unauthorized("session_expired", "session has expired")
+)
+
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type userServiceImpl struct {
-	repo repository.UserQuery
+	repo        repository.UserQuery
+	repoSession repository.SessionQuery
+	repoEntity  repository.EntityQuery
+	keys        *helper.KeySet
+	policy      helper.ClaimPolicy
+	bus         *event.Bus
 }
 
-func NewUserService(repo repository.UserQuery) UserService {
-	return &userServiceImpl{repo: repo}
+func NewUserService(repo repository.UserQuery, repoSession repository.SessionQuery, repoEntity repository.EntityQuery, keys *helper.KeySet, policy helper.ClaimPolicy, bus *event.Bus) UserService {
+	return &userServiceImpl{
+		repo:        repo,
+		repoSession: repoSession,
+		repoEntity:  repoEntity,
+		bus:         bus,
+		keys:        keys,
+		policy:      policy,
+	}
 }
 
-func (u *userServiceImpl) GetUsers(ctx context.Context) ([]model.User, error) {
-	users, err := u.repo.GetUsers(ctx)
+func (u *userServiceImpl) GetUsers(ctx context.Context, params pagination.Params) (pagination.Page[model.User], error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsers")
+	defer span.End()
+
+	users, err := u.repo.GetUsers(ctx, params)
 	if err != nil {
-		return nil, err
+		return pagination.Page[model.User]{}, err
 	}
 	return users, err
 
 }
 func (u *userServiceImpl) GetUsersByID(ctx context.Context, id uint64) (model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsersByID")
+	defer span.End()
+
 	user, err := u.repo.GetUsersByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.User{}, ErrUserNotFound
+	}
 	if err != nil {
 		return model.User{}, err
 	}
@@ -48,9 +106,12 @@ func (u *userServiceImpl) GetUsersByID(ctx context.Context, id uint64) (model.Us
 }
 
 func (u *userServiceImpl) SignUp(ctx context.Context, userSignUp model.UserSignUp) (model.UserView, error) {
+	ctx, span := tracing.Start(ctx, "UserService.SignUp")
+	defer span.End()
+
 	dob, err := time.Parse("2006-01-02", userSignUp.Dob)
 	if err != nil {
-		return model.UserView{}, errors.New("invalid date of birth format")
+		return model.UserView{}, ErrInvalidDob
 	}
 
 	// count age
@@ -62,27 +123,28 @@ func (u *userServiceImpl) SignUp(ctx context.Context, userSignUp model.UserSignU
 
 	// check age < 8
 	if age < 8 {
-		return model.UserView{}, errors.New("age must be at least 8 years old")
+		return model.UserView{}, ErrTooYoung
 	}
 	user := model.User{
 		Username: userSignUp.Username,
 		Email:    userSignUp.Email,
 		Dob:      dob,
+		Role:     model.RoleUser,
 	}
 	// encryption password
 	// hashing
-	pass, err := helper.GenerateHash(userSignUp.Password)
+	pass, err := helper.GenerateHash(ctx, userSignUp.Password)
 	if err != nil {
 		return model.UserView{}, err
 	}
 	user.Password = pass
 
-	getUserByEmail, err := u.repo.GetUserByEmail(ctx, user.Email)
-	if err != nil {
-		return model.UserView{}, err
+	_, err = u.repo.GetUserByEmail(ctx, user.Email)
+	if err == nil {
+		return model.UserView{}, ErrEmailTaken
 	}
-	if getUserByEmail.Email == user.Email {
-		return model.UserView{}, errors.New("email already exist")
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return model.UserView{}, err
 	}
 
 	// store to db
@@ -95,50 +157,202 @@ func (u *userServiceImpl) SignUp(ctx context.Context, userSignUp model.UserSignU
 		Username: createdUser.Username,
 		Email:    createdUser.Email,
 		Dob:      createdUser.Dob,
+		Role:     createdUser.Role,
 	}
 
 	return printUser, err
 }
 
-func (u *userServiceImpl) GenerateUserAccessToken(ctx context.Context, user model.User) (token string, err error) {
-	// generate claim
+// GenerateUserTokens opens a new session for the user and returns its first
+// access/refresh token pair.
+func (u *userServiceImpl) GenerateUserTokens(ctx context.Context, user model.User) (model.UserToken, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GenerateUserTokens")
+	defer span.End()
+
+	accessJti, err := helper.GenerateRandomID(ctx)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	refreshJti, err := helper.GenerateRandomID(ctx)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	session, err := u.repoSession.CreateSession(ctx, model.Session{
+		UserID:     user.ID,
+		AccessJti:  accessJti,
+		RefreshJti: refreshJti,
+		ExpiresAt:  time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	return u.signUserTokens(ctx, user, session)
+}
+
+// RefreshUserTokens exchanges a refresh token for a new token pair. Every
+// refresh token can be used once; presenting one that was already rotated
+// out means it leaked, so the whole session is revoked.
+func (u *userServiceImpl) RefreshUserTokens(ctx context.Context, refreshToken string) (model.UserToken, error) {
+	ctx, span := tracing.Start(ctx, "UserService.RefreshUserTokens")
+	defer span.End()
+
+	claim := model.RefreshClaim{}
+	if err := u.keys.ParseClaim(ctx, refreshToken, model.SubjectRefreshToken, u.policy, &claim); err != nil {
+		return model.UserToken{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	session, err := u.getLiveSession(ctx, claim.SessionID)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	if session.RefreshJti != claim.Jti {
+		if err := u.repoSession.RevokeSession(ctx, session.ID); err != nil {
+			return model.UserToken{}, err
+		}
+		return model.UserToken{}, ErrTokenReused
+	}
+
+	user, err := u.repo.GetUsersByID(ctx, session.UserID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UserToken{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return model.UserToken{}, err
+	}
+
+	oldRefreshJti := session.RefreshJti
+	if session.AccessJti, err = helper.GenerateRandomID(ctx); err != nil {
+		return model.UserToken{}, err
+	}
+	if session.RefreshJti, err = helper.GenerateRandomID(ctx); err != nil {
+		return model.UserToken{}, err
+	}
+	session.ExpiresAt = time.Now().Add(refreshTokenTTL)
+
+	rotated, err := u.repoSession.RotateSession(ctx, session, oldRefreshJti)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	if !rotated {
+		return model.UserToken{}, ErrTokenReused
+	}
+
+	return u.signUserTokens(ctx, user, session)
+}
+
+// ValidateSession reports whether an access token with the given jti still
+// belongs to a live session.
+func (u *userServiceImpl) ValidateSession(ctx context.Context, sessionID uint64, jti string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ValidateSession")
+	defer span.End()
+
+	session, err := u.getLiveSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.AccessJti != jti {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// getLiveSession returns the session, unless it is gone, revoked or
+// expired.
+func (u *userServiceImpl) getLiveSession(ctx context.Context, sessionID uint64) (model.Session, error) {
+	session, err := u.repoSession.GetSessionByID(ctx, sessionID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return model.Session{}, err
+	}
+	if session.RevokedAt != nil {
+		return model.Session{}, ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return model.Session{}, ErrSessionExpired
+	}
+	return session, nil
+}
+
+func (u *userServiceImpl) RevokeSession(ctx context.Context, sessionID uint64) error {
+	ctx, span := tracing.Start(ctx, "UserService.RevokeSession")
+	defer span.End()
+
+	return u.repoSession.RevokeSession(ctx, sessionID)
+}
+
+func (u *userServiceImpl) signUserTokens(ctx context.Context, user model.User, session model.Session) (model.UserToken, error) {
 	now := time.Now()
 
-	claim := model.StandardClaim{
-		Jti: fmt.Sprintf("%v", time.Now().UnixNano()),
-		Iss: "go-middleware",
-		Aud: "golang-006",
-		Sub: "access-token",
-		Exp: uint64(now.Add(time.Hour).Unix()),
-		Iat: uint64(now.Unix()),
-		Nbf: uint64(now.Unix()),
+	accessClaim := model.AccessClaim{
+		StandardClaim: model.StandardClaim{
+			Jti: session.AccessJti,
+			Iss: u.policy.Issuer,
+			Aud: u.policy.Audience,
+			Sub: model.SubjectAccessToken,
+			Exp: uint64(now.Add(accessTokenTTL).Unix()),
+			Iat: uint64(now.Unix()),
+			Nbf: uint64(now.Unix()),
+		},
+		SessionID: session.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Dob:       user.Dob,
+	}
+	accessToken, err := u.keys.GenerateToken(ctx, accessClaim)
+	if err != nil {
+		return model.UserToken{}, err
 	}
 
-	userClaim := model.AccessClaim{
-		StandardClaim: claim,
-		UserID:        user.ID,
-		Username:      user.Username,
-		Dob:           user.Dob,
+	refreshClaim := model.RefreshClaim{
+		StandardClaim: model.StandardClaim{
+			Jti: session.RefreshJti,
+			Iss: u.policy.Issuer,
+			Aud: u.policy.Audience,
+			Sub: model.SubjectRefreshToken,
+			Exp: uint64(session.ExpiresAt.Unix()),
+			Iat: uint64(now.Unix()),
+			Nbf: uint64(now.Unix()),
+		},
+		SessionID: session.ID,
+		UserID:    user.ID,
+	}
+	refreshToken, err := u.keys.GenerateToken(ctx, refreshClaim)
+	if err != nil {
+		return model.UserToken{}, err
 	}
 
-	token, err = helper.GenerateToken(userClaim)
-	return
+	return model.UserToken{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
 }
 
 func (u *userServiceImpl) CheckCredentials(ctx context.Context, email string, password string) (model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CheckCredentials")
+	defer span.End()
+
 	// Retrieve user by email
 	user, err := u.repo.GetUserByEmail(ctx, email)
+	// do not tell whether the email or the password is wrong
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.User{}, ErrInvalidCredentials
+	}
 	if err != nil {
 		return model.User{}, err
 	}
 
-	// Check if user exists
-	if user.ID == 0 {
-		return model.User{}, errors.New("user not found")
-	}
-
 	// Compare hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return model.User{}, ErrInvalidCredentials
+	}
 	if err != nil {
 		return model.User{}, err
 	}
@@ -148,24 +362,64 @@ func (u *userServiceImpl) CheckCredentials(ctx context.Context, email string, pa
 }
 
 func (u *userServiceImpl) EditUser(ctx context.Context, id uint64, user model.User) (model.User, error) {
-	// Perform validation or additional checks here if necessary
+	ctx, span := tracing.Start(ctx, "UserService.EditUser")
+	defer span.End()
+
+	// roles are only changed through UpdateUserRole
+	user.Role = ""
 
 	// Call repository to edit user
 	updatedUser, err := u.repo.EditUser(ctx, id, user)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.User{}, ErrUserNotFound
+	}
 	if err != nil {
 		return model.User{}, err
 	}
 	return updatedUser, nil
 }
 
-func (u *userServiceImpl) DeleteUsersById(ctx context.Context, id uint64) (model.User, error) {
+func (u *userServiceImpl) UpdateUserRole(ctx context.Context, id uint64, role string) (model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUserRole")
+	defer span.End()
+
+	if !model.IsValidRole(role) {
+		return model.User{}, ErrInvalidRole
+	}
+
 	user, err := u.repo.GetUsersByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.User{}, ErrUserNotFound
+	}
 	if err != nil {
 		return model.User{}, err
 	}
-	// if user doesn't exist, return
-	if user.ID == 0 {
-		return model.User{}, nil
+
+	err = u.repo.UpdateUserRole(ctx, id, role)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// tokens carry the role, so force the user to log in again
+	err = u.repoSession.RevokeSessionsByUserID(ctx, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user.Role = role
+	return user, nil
+}
+
+func (u *userServiceImpl) DeleteUsersById(ctx context.Context, id uint64) (model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUsersById")
+	defer span.End()
+
+	user, err := u.repo.GetUsersByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return model.User{}, err
 	}
 
 	// delete user by id
@@ -174,5 +428,109 @@ func (u *userServiceImpl) DeleteUsersById(ctx context.Context, id uint64) (model
 		return model.User{}, err
 	}
 
+	// log the deleted account out everywhere
+	err = u.repoSession.RevokeSessionsByUserID(ctx, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
 	return user, err
 }
+
+// GetUserProfile returns the user together with their follower and
+// following counts.
+func (u *userServiceImpl) GetUserProfile(ctx context.Context, id uint64) (model.UserProfile, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserProfile")
+	defer span.End()
+
+	user, err := u.repo.GetUsersByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return model.UserProfile{}, ErrUserNotFound
+	}
+	if err != nil {
+		return model.UserProfile{}, err
+	}
+	followers, following, err := u.repo.CountFollows(ctx, id)
+	if err != nil {
+		return model.UserProfile{}, err
+	}
+	return model.UserProfile{User: user, FollowerCount: followers, FollowingCount: following}, nil
+}
+
+func (u *userServiceImpl) FollowUser(ctx context.Context, followerID uint64, followingID uint64) error {
+	ctx, span := tracing.Start(ctx, "UserService.FollowUser")
+	defer span.End()
+
+	if followerID == followingID {
+		return ErrSelfFollow
+	}
+	if err := u.checkUserExists(ctx, followingID); err != nil {
+		return err
+	}
+	created, err := u.repo.FollowUser(ctx, followerID, followingID)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAlreadyFollowing
+	}
+	u.bus.Publish(event.Event{
+		Type:        event.TYPE_FOLLOW_CREATED,
+		ActorID:     followerID,
+		RecipientID: followingID,
+	})
+	return nil
+}
+
+func (u *userServiceImpl) UnfollowUser(ctx context.Context, followerID uint64, followingID uint64) error {
+	ctx, span := tracing.Start(ctx, "UserService.UnfollowUser")
+	defer span.End()
+
+	deleted, err := u.repo.UnfollowUser(ctx, followerID, followingID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFollowing
+	}
+	return nil
+}
+
+func (u *userServiceImpl) GetFollowers(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetFollowers")
+	defer span.End()
+
+	if err := u.checkUserExists(ctx, id); err != nil {
+		return pagination.Page[model.FollowUser]{}, err
+	}
+	return u.repo.GetFollowers(ctx, id, params)
+}
+
+func (u *userServiceImpl) GetFollowing(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.FollowUser], error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetFollowing")
+	defer span.End()
+
+	if err := u.checkUserExists(ctx, id); err != nil {
+		return pagination.Page[model.FollowUser]{}, err
+	}
+	return u.repo.GetFollowing(ctx, id, params)
+}
+
+// GetMentions lists the photos and comments that @mention the user.
+func (u *userServiceImpl) GetMentions(ctx context.Context, id uint64, params pagination.Params) (pagination.Page[model.MentionView], error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetMentions")
+	defer span.End()
+
+	if err := u.checkUserExists(ctx, id); err != nil {
+		return pagination.Page[model.MentionView]{}, err
+	}
+	return u.repoEntity.GetMentions(ctx, id, params)
+}
+
+func (u *userServiceImpl) checkUserExists(ctx context.Context, id uint64) error {
+	_, err := u.repo.GetUsersByID(ctx, id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geedotrar/mygram/internal/apperror"
+	"github.com/geedotrar/mygram/internal/model"
+	"github.com/geedotrar/mygram/internal/repository"
+	"github.com/geedotrar/mygram/pkg/helper"
+)
+
+// sessionStore keeps sessions in memory with the semantics of the sessions
+// table: a rotation only applies while the old refresh jti is current.
+type sessionStore struct {
+	sessions map[uint64]model.Session
+	nextID   uint64
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: map[uint64]model.Session{}}
+}
+
+func (s *sessionStore) CreateSession(ctx context.Context, session model.Session) (model.Session, error) {
+	s.nextID++
+	session.ID = s.nextID
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *sessionStore) GetSessionByID(ctx context.Context, id uint64) (model.Session, error) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return model.Session{}, apperror.ErrNotFound
+	}
+	return session, nil
+}
+
+func (s *sessionStore) RotateSession(ctx context.Context, session model.Session, oldRefreshJti string) (bool, error) {
+	stored, ok := s.sessions[session.ID]
+	if !ok || stored.RevokedAt != nil || stored.RefreshJti != oldRefreshJti {
+		return false, nil
+	}
+	stored.AccessJti = session.AccessJti
+	stored.RefreshJti = session.RefreshJti
+	stored.ExpiresAt = session.ExpiresAt
+	s.sessions[session.ID] = stored
+	return true, nil
+}
+
+func (s *sessionStore) RevokeSession(ctx context.Context, id uint64) error {
+	if session, ok := s.sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		s.sessions[id] = session
+	}
+	return nil
+}
+
+func (s *sessionStore) RevokeSessionsByUserID(ctx context.Context, userID uint64) error {
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			s.RevokeSession(ctx, id)
+		}
+	}
+	return nil
+}
+
+// userByID knows a single user.
+type userByID struct {
+	repository.UserQuery
+	user model.User
+}
+
+func (q *userByID) GetUsersByID(ctx context.Context, id uint64) (model.User, error) {
+	if id != q.user.ID {
+		return model.User{}, apperror.ErrNotFound
+	}
+	return q.user, nil
+}
+
+var testPolicy = helper.ClaimPolicy{Issuer: "mygram", Audience: "mygram-api", Leeway: 30 * time.Second}
+
+func newTestUserService(t *testing.T, sessions *sessionStore) (UserService, *helper.KeySet) {
+	t.Helper()
+	keys, err := helper.NewKeySet("k1", helper.SigningKey{ID: "k1", Algorithm: helper.ALG_HS512, Secret: []byte(strings.Repeat("s", helper.MIN_SECRET_BYTES))})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	user := &userByID{user: model.User{ID: 1, Username: "alice", Role: "user"}}
+	return NewUserService(user, sessions, nil, keys, testPolicy, nil), keys
+}
+
+// validateAccess runs the session check the authorization middleware does
+// for an access token.
+func validateAccess(t *testing.T, svc UserService, keys *helper.KeySet, token string) error {
+	t.Helper()
+	claim := model.AccessClaim{}
+	if err := keys.ParseClaim(context.Background(), token, model.SubjectAccessToken, testPolicy, &claim); err != nil {
+		t.Fatalf("ParseClaim: %v", err)
+	}
+	return svc.ValidateSession(context.Background(), claim.SessionID, claim.Jti)
+}
+
+func TestRefreshUserTokensRotates(t *testing.T) {
+	ctx := context.Background()
+	sessions := newSessionStore()
+	svc, keys := newTestUserService(t, sessions)
+
+	first, err := svc.GenerateUserTokens(ctx, model.User{ID: 1, Username: "alice"})
+	if err != nil {
+		t.Fatalf("GenerateUserTokens: %v", err)
+	}
+	second, err := svc.RefreshUserTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshUserTokens: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh returned the same tokens")
+	}
+
+	if err := validateAccess(t, svc, keys, second.AccessToken); err != nil {
+		t.Errorf("new access token: %v", err)
+	}
+	if err := validateAccess(t, svc, keys, first.AccessToken); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("rotated out access token: got %v, want ErrSessionNotFound", err)
+	}
+	if _, err := svc.RefreshUserTokens(ctx, second.RefreshToken); err != nil {
+		t.Errorf("refreshing again with the new token: %v", err)
+	}
+}
+
+func TestRefreshUserTokensReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	sessions := newSessionStore()
+	svc, keys := newTestUserService(t, sessions)
+
+	first, err := svc.GenerateUserTokens(ctx, model.User{ID: 1, Username: "alice"})
+	if err != nil {
+		t.Fatalf("GenerateUserTokens: %v", err)
+	}
+	second, err := svc.RefreshUserTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshUserTokens: %v", err)
+	}
+
+	// the old refresh token leaked and is replayed
+	if _, err := svc.RefreshUserTokens(ctx, first.RefreshToken); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("replay: got %v, want ErrTokenReused", err)
+	}
+	if sessions.sessions[1].RevokedAt == nil {
+		t.Fatal("session not revoked after a replay")
+	}
+
+	if err := validateAccess(t, svc, keys, second.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token after the replay: got %v, want ErrSessionRevoked", err)
+	}
+	if _, err := svc.RefreshUserTokens(ctx, second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refresh token after the replay: got %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRefreshUserTokensRejectsAccessToken(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTestUserService(t, newSessionStore())
+
+	tokens, err := svc.GenerateUserTokens(ctx, model.User{ID: 1, Username: "alice"})
+	if err != nil {
+		t.Fatalf("GenerateUserTokens: %v", err)
+	}
+	if _, err := svc.RefreshUserTokens(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) || !errors.Is(err, helper.ErrTokenSubject) {
+		t.Errorf("got %v, want ErrInvalidToken for a subject mismatch", err)
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := NewLocalStorage(root)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	if err := store.Put(ctx, "photos/1/a.jpg", strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "photos", "1", "a.jpg")); err != nil || string(b) != "jpeg" {
+		t.Fatalf("stored %q (%v), want jpeg", b, err)
+	}
+	// the temporary file is renamed, not left behind
+	if entries, _ := os.ReadDir(filepath.Join(root, "photos", "1")); len(entries) != 1 {
+		t.Errorf("got %d files in the photo directory, want 1", len(entries))
+	}
+
+	obj, err := store.Get(ctx, "photos/1/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(b) != "jpeg" || obj.Size != 4 || obj.ModTime.IsZero() {
+		t.Errorf("got %q, size %d, modified %v", b, obj.Size, obj.ModTime)
+	}
+
+	if err := store.Delete(ctx, "photos/1/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "photos/1/a.jpg"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrObjectNotFound", err)
+	}
+	if err := store.Delete(ctx, "photos/1/a.jpg"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestLocalStorageRejectsKeysOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	store, err := NewLocalStorage(filepath.Join(parent, "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	outside := filepath.Join(parent, "outside.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../outside.txt", "photos/../../outside.txt", outside, "/" + filepath.ToSlash(outside)} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Get(%q): got %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+	if b, err := os.ReadFile(outside); err != nil || string(b) != "secret" {
+		t.Errorf("file outside the root changed: %q (%v)", b, err)
+	}
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyID     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion          = "eu-west-1"
+	testBucket          = "mygram"
+)
+
+// fakeS3 is an S3 stand-in keeping objects in memory. It answers 403 to
+// any request whose SigV4 signature it cannot verify itself.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	methods []string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, Storage) {
+	f := &fakeS3{t: t, objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	store, err := NewS3Storage(S3Config{
+		Endpoint:        server.URL + "/",
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: testSecretAccessKey,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return f, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+	// path-style addressing: /<bucket>/<key>
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket || key == "" {
+		f.t.Errorf("got path %q, want /%s/<key>", r.URL.Path, testBucket)
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.methods = append(f.methods, r.Method)
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		if int64(len(b)) != r.ContentLength {
+			f.t.Errorf("got %d bytes, Content-Length %d", len(b), r.ContentLength)
+		}
+		f.objects[key] = b
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		b, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Header().Set("Last-Modified", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+		w.Write(b)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) object(key string) ([]byte, string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[key]
+	return b, f.types[key], ok
+}
+
+// verify recomputes the AWS Signature Version 4 of r from the credentials
+// the store was given.
+func (f *fakeS3) verify(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("bad X-Amz-Date %q", amzDate)
+	}
+	if d := time.Since(signedAt); d < -time.Minute || d > time.Minute {
+		return fmt.Errorf("X-Amz-Date %s is %v off", amzDate, d)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != "UNSIGNED-PAYLOAD" {
+		return fmt.Errorf("X-Amz-Content-Sha256 is %q", payloadHash)
+	}
+
+	date := signedAt.Format("20060102")
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" +
+		payloadHash
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + testSecretAccessKey)
+	for _, part := range []string{date, testRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=" + testAccessKeyID + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date" +
+		", Signature=" + hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if got := r.Header.Get("Authorization"); got != want {
+		return fmt.Errorf("Authorization is\n%s\nwant\n%s", got, want)
+	}
+	return nil
+}
+
+func TestS3Storage(t *testing.T) {
+	ctx := context.Background()
+	fake, store := newFakeS3(t)
+
+	if err := store.Put(ctx, "photos/1/a.jpg", strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if b, contentType, _ := fake.object("photos/1/a.jpg"); string(b) != "jpeg" || contentType != "image/jpeg" {
+		t.Fatalf("stored %q as %q", b, contentType)
+	}
+
+	obj, err := store.Get(ctx, "photos/1/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, _ := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if string(b) != "jpeg" || obj.Size != 4 || !obj.ModTime.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %q, size %d, modified %v", b, obj.Size, obj.ModTime)
+	}
+
+	if err := store.Delete(ctx, "photos/1/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, ok := fake.object("photos/1/a.jpg"); ok {
+		t.Error("object still stored after Delete")
+	}
+	if _, err := store.Get(ctx, "photos/1/a.jpg"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrObjectNotFound", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	want := []string{http.MethodPut, http.MethodGet, http.MethodDelete, http.MethodGet}
+	if strings.Join(fake.methods, " ") != strings.Join(want, " ") {
+		t.Errorf("server saw %v, want %v", fake.methods, want)
+	}
+}
+
+func TestS3StorageErrors(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer server.Close()
+	store, err := NewS3Storage(S3Config{Endpoint: server.URL, Bucket: testBucket, AccessKeyID: "a", SecretAccessKey: "b"})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	if err := store.Put(ctx, "photos/1/a.jpg", strings.NewReader("x"), 1, "image/jpeg"); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put: got %v, want the S3 error", err)
+	}
+	if _, err := store.Get(ctx, "photos/1/a.jpg"); err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get: got %v, want the S3 error", err)
+	}
+	if err := store.Delete(ctx, "photos/1/a.jpg"); err == nil {
+		t.Error("Delete: got no error")
+	}
+}
+
+func TestS3StorageRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	_, store := newFakeS3(t)
+
+	for _, key := range []string{"../a.jpg", "/photos/1/a.jpg", "photos/1/a b.jpg"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "image/jpeg"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil {
+			t.Errorf("Get(%q) succeeded", key)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+}
+
+func TestNewS3StorageRequiresCredentials(t *testing.T) {
+	if _, err := NewS3Storage(S3Config{Endpoint: "http://localhost:9000", Bucket: testBucket}); err == nil {
+		t.Error("got no error without credentials")
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// Object is a stored blob opened for reading. Callers must close Body.
+type Object struct {
+	Body    io.ReadCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage keeps uploaded files. Keys are slash separated relative paths such
+// as "photos/12/3f9a.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config picks a backend: Driver is "local" (the default), storing files
+// under LocalRoot, or "s3".
+type Config struct {
+	Driver    string
+	LocalRoot string
+	S3        S3Config
+}
+
+func NewStorage(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		root := cfg.LocalRoot
+		if root == "" {
+			root = "./uploads"
+		}
+		return NewLocalStorage(root)
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// validKey keeps keys to relative paths that never leave the local root,
+// made of characters that need no escaping so the S3 request path and the
+// canonical URI in its signature are the same string.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-", r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "testing"
+
+func TestValidKey(t *testing.T) {
+	valid := []string{"photos/12/3f9a.jpg", "photos/12/3f9a_thumbnail.jpg", "a", "a-b.C_1"}
+	invalid := []string{
+		"",
+		"/photos/12/3f9a.jpg",
+		"/etc/passwd",
+		"..",
+		"../photos/1.jpg",
+		"photos/../../etc/passwd",
+		"photos/..",
+		"photos/1 2.jpg",
+		"photos/1%2F.jpg",
+		"photos/1?.jpg",
+		`photos\1.jpg`,
+		"photos/é.jpg",
+	}
+	for _, key := range valid {
+		if !validKey(key) {
+			t.Errorf("validKey(%q) = false, want true", key)
+		}
+	}
+	for _, key := range invalid {
+		if validKey(key) {
+			t.Errorf("validKey(%q) = true, want false", key)
+		}
+	}
+}
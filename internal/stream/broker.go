@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+const (
+	TYPE_COMMENT      = "comment"
+	TYPE_NOTIFICATION = "notification"
+)
+
+var ErrBrokerClosed = errors.New("stream broker is closed")
+
+// Message is pushed to every client subscribed to Topic. Type names the
+// kind of Data, which is already encoded so brokers can pass it on as is.
+type Message struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// PhotoTopic carries the new comments on a photo.
+func PhotoTopic(photoID uint64) string {
+	return "photo:" + strconv.FormatUint(photoID, 10)
+}
+
+// UserTopic carries the new notifications of a user.
+func UserTopic(userID uint64) string {
+	return "user:" + strconv.FormatUint(userID, 10)
+}
+
+// Broker delivers the published messages to the subscribers of every
+// instance, so a client receives a message whichever instance it is
+// connected to. Subscribers must not block.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Subscribe(handler func(msg Message)) (unsubscribe func(), err error)
+	Close() error
+}
+
+// MemoryBroker is a Broker for a single instance, delivering messages
+// within the process.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(msg Message)
+	nextID   int
+	closed   bool
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: map[int]func(msg Message){}}
+}
+
+func (m *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrBrokerClosed
+	}
+	for _, handler := range m.handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (m *MemoryBroker) Subscribe(handler func(msg Message)) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrBrokerClosed
+	}
+	id := m.nextID
+	m.nextID++
+	m.handlers[id] = handler
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.handlers, id)
+	}, nil
+}
+
+func (m *MemoryBroker) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.handlers = map[int]func(msg Message){}
+	return nil
+}
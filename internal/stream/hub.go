@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// subscriptionBuffer is how many messages a slow client may fall behind
+// before messages to it are dropped.
+const subscriptionBuffer = 16
+
+// Hub fans the messages received from the broker out to the clients
+// connected to this instance.
+type Hub struct {
+	broker      Broker
+	mu          sync.RWMutex
+	topics      map[string]map[*Subscription]struct{}
+	unsubscribe func()
+	running     atomic.Bool
+}
+
+// Subscription receives the messages of its topics on C until it is
+// closed, either by the client or by the hub stopping.
+type Subscription struct {
+	C      <-chan Message
+	c      chan Message
+	topics []string
+	hub    *Hub
+	once   sync.Once
+}
+
+func NewHub(broker Broker) *Hub {
+	return &Hub{
+		broker: broker,
+		topics: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// Start subscribes the hub to the broker.
+func (h *Hub) Start() error {
+	unsubscribe, err := h.broker.Subscribe(h.dispatch)
+	if err != nil {
+		return err
+	}
+	h.unsubscribe = unsubscribe
+	h.running.Store(true)
+	return nil
+}
+
+// Publish encodes data and hands it to the broker for every instance to
+// deliver.
+func (h *Hub) Publish(ctx context.Context, topic string, msgType string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return h.broker.Publish(ctx, Message{Topic: topic, Type: msgType, Data: encoded})
+}
+
+// Subscribe registers a client for the given topics.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	c := make(chan Message, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, topics: topics, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.running.Load() {
+		close(c)
+		return sub
+	}
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = map[*Subscription]struct{}{}
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+// Close unregisters the subscription and closes C.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		for _, topic := range sub.topics {
+			delete(h.topics[topic], sub)
+			if len(h.topics[topic]) == 0 {
+				delete(h.topics, topic)
+			}
+		}
+		close(sub.c)
+	})
+}
+
+// dispatch never blocks the broker: a message is dropped for a client
+// whose buffer is full.
+func (h *Hub) dispatch(msg Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.topics[msg.Topic] {
+		select {
+		case sub.c <- msg:
+		default:
+			slog.Warn("stream subscriber is too slow, dropping message", "topic", msg.Topic)
+		}
+	}
+}
+
+// Running reports whether the hub has been started and not stopped.
+func (h *Hub) Running() bool {
+	return h.running.Load()
+}
+
+// Stop unsubscribes from the broker and closes every subscription, which
+// ends the open streams.
+func (h *Hub) Stop() {
+	if !h.running.CompareAndSwap(true, false) {
+		return
+	}
+	h.unsubscribe()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.topics {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
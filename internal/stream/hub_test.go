@@ -0,0 +1,171 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	broker := NewMemoryBroker()
+	t.Cleanup(func() { broker.Close() })
+	hub := NewHub(broker)
+	if err := hub.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+func publish(t *testing.T, hub *Hub, topic string, msgType string, data any) {
+	t.Helper()
+	if err := hub.Publish(context.Background(), topic, msgType, data); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed, want a message")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return Message{}
+}
+
+// MemoryBroker dispatches synchronously, so anything published has already
+// been delivered.
+func expectNothing(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.C:
+		if ok {
+			t.Fatalf("got message %+v on %v, want none", msg, sub.topics)
+		}
+		t.Fatalf("subscription to %v closed, want it open", sub.topics)
+	default:
+	}
+}
+
+func expectClosed(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.C:
+		if ok {
+			t.Fatalf("got message %+v, want the subscription closed", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription still open")
+	}
+}
+
+func TestHubFansOutToEverySubscriber(t *testing.T) {
+	hub := startHub(t)
+	subs := []*Subscription{
+		hub.Subscribe(PhotoTopic(1)),
+		hub.Subscribe(PhotoTopic(1)),
+		hub.Subscribe(UserTopic(9), PhotoTopic(1)),
+	}
+	other := hub.Subscribe(PhotoTopic(2))
+
+	publish(t, hub, PhotoTopic(1), TYPE_COMMENT, map[string]string{"message": "hello"})
+
+	for _, sub := range subs {
+		msg := receive(t, sub)
+		if msg.Topic != PhotoTopic(1) || msg.Type != TYPE_COMMENT || string(msg.Data) != `{"message":"hello"}` {
+			t.Errorf("got %+v (data %s)", msg, msg.Data)
+		}
+	}
+	expectNothing(t, other)
+}
+
+func TestHubIsolatesUserNotifications(t *testing.T) {
+	hub := startHub(t)
+	alice := hub.Subscribe(UserTopic(1), PhotoTopic(5))
+	bob := hub.Subscribe(UserTopic(2), PhotoTopic(5))
+
+	publish(t, hub, UserTopic(1), TYPE_NOTIFICATION, map[string]int{"id": 1})
+
+	if msg := receive(t, alice); msg.Topic != UserTopic(1) {
+		t.Errorf("alice got a message on %s", msg.Topic)
+	}
+	expectNothing(t, bob)
+
+	publish(t, hub, UserTopic(2), TYPE_NOTIFICATION, map[string]int{"id": 2})
+
+	if msg := receive(t, bob); msg.Topic != UserTopic(2) {
+		t.Errorf("bob got a message on %s", msg.Topic)
+	}
+	expectNothing(t, alice)
+}
+
+func TestSubscriptionCloseUnsubscribes(t *testing.T) {
+	hub := startHub(t)
+	gone := hub.Subscribe(UserTopic(1), PhotoTopic(1))
+	staying := hub.Subscribe(PhotoTopic(1))
+
+	gone.Close()
+	gone.Close()
+
+	expectClosed(t, gone)
+	hub.mu.RLock()
+	_, userTopic := hub.topics[UserTopic(1)]
+	_, subscribed := hub.topics[PhotoTopic(1)][gone]
+	hub.mu.RUnlock()
+	if userTopic || subscribed {
+		t.Error("closed subscription still registered")
+	}
+
+	publish(t, hub, PhotoTopic(1), TYPE_COMMENT, nil)
+	receive(t, staying)
+}
+
+func TestHubStopEndsStreams(t *testing.T) {
+	broker := NewMemoryBroker()
+	hub := NewHub(broker)
+	if err := hub.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	subs := []*Subscription{
+		hub.Subscribe(UserTopic(1)),
+		hub.Subscribe(UserTopic(2), PhotoTopic(1)),
+	}
+
+	hub.Stop()
+	hub.Stop()
+
+	if hub.Running() {
+		t.Error("hub still running after Stop")
+	}
+	for _, sub := range subs {
+		expectClosed(t, sub)
+		// closing after the hub did must not panic
+		sub.Close()
+	}
+	expectClosed(t, hub.Subscribe(UserTopic(3)))
+
+	broker.mu.RLock()
+	handlers := len(broker.handlers)
+	broker.mu.RUnlock()
+	if handlers != 0 {
+		t.Errorf("hub still subscribed to the broker: %d handlers", handlers)
+	}
+}
+
+func TestMemoryBrokerClosed(t *testing.T) {
+	broker := NewMemoryBroker()
+	broker.Close()
+
+	if err := broker.Publish(context.Background(), Message{Topic: UserTopic(1)}); err != ErrBrokerClosed {
+		t.Errorf("Publish: got %v, want ErrBrokerClosed", err)
+	}
+	if err := NewHub(broker).Start(); err != ErrBrokerClosed {
+		t.Errorf("Start: got %v, want ErrBrokerClosed", err)
+	}
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/geedotrar/mygram/internal/config"
+	"github.com/geedotrar/mygram/internal/version"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_NONE   = "none"
+	EXPORTER_STDOUT = "stdout"
+	EXPORTER_OTLP   = "otlp"
+)
+
+// INSTRUMENTATION names the tracer of every span started by the app.
+const INSTRUMENTATION = "github.com/geedotrar/mygram"
+
+// Setup installs the tracer provider and the W3C trace context and baggage
+// propagators for cfg. The returned function flushes the pending spans and
+// must be called before exiting. With the none exporter spans are still
+// created, so trace IDs reach the logs, but never exported.
+func Setup(ctx context.Context, cfg config.Tracing) (func(ctx context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case EXPORTER_NONE:
+		return Install(nil, cfg.ServiceName, cfg.SampleRatio)
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case EXPORTER_OTLP:
+		// endpoint, headers and TLS come from OTEL_EXPORTER_OTLP_*
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		err = fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Install(sdktrace.NewBatchSpanProcessor(exporter), cfg.ServiceName, cfg.SampleRatio)
+}
+
+// Install makes a provider handing the sampled spans to processor the
+// global one, along with the propagators. Tests pass a simple processor
+// over a tracetest.InMemoryExporter, which then holds each span as soon as
+// it ends. A nil processor drops every span.
+func Install(processor sdktrace.SpanProcessor, serviceName string, sampleRatio float64) (func(ctx context.Context) error, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version.Get().Commit),
+	))
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	}
+	if processor != nil {
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the one in ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(INSTRUMENTATION).Start(ctx, name, opts...)
+}
+
+// End records err on span, when there is one, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEnd(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Install(sdktrace.NewSimpleSpanProcessor(exporter), "test", 1)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, failed := Start(ctx, "failed")
+	End(failed, errors.New("boom"))
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	got := spans[0]
+	if got.Name != "failed" || got.Status.Code != codes.Error || got.Status.Description != "boom" {
+		t.Errorf("failed span: %s %v %q", got.Name, got.Status.Code, got.Status.Description)
+	}
+	if len(got.Events) != 1 || got.Events[0].Name != "exception" {
+		t.Errorf("failed span events %v, want the recorded error", got.Events)
+	}
+	if got.Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Error("failed span is not a child of its parent")
+	}
+	if ok := spans[1]; ok.Status.Code != codes.Unset || len(ok.Events) != 0 {
+		t.Errorf("parent span: status %v events %v, want neither", ok.Status.Code, ok.Events)
+	}
+}
+
+func TestInstallSampling(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Install(sdktrace.NewSimpleSpanProcessor(exporter), "test", 0)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	_, span := Start(context.Background(), "dropped")
+	End(span, nil)
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("got %d spans with a sample ratio of 0", len(spans))
+	}
+	if !span.SpanContext().TraceID().IsValid() {
+		t.Error("unsampled span has no trace ID for the logs")
+	}
+}
@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	TYPE_MENTION = "mention"
+	TYPE_HASHTAG = "hashtag"
+)
+
+// MAX_TAG_LENGTH is the longest hashtag, in runes, that fits hashtags.tag.
+const MAX_TAG_LENGTH = 100
+
+// Entity is a @mention or #hashtag found in a caption or comment. Start and
+// End are offsets in runes (code points) into the text and cover the sigil,
+// so text[Start:End] is "@name" or "#tag". Text holds the name without it.
+type Entity struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	UserID uint64 `json:"user_id,omitempty"`
+}
+
+// Parse returns the entities of text in order of appearance. A sigil only
+// starts an entity at the beginning of a word, so e-mail addresses and
+// things like "C#" are left alone. Hashtags longer than MAX_TAG_LENGTH are
+// not hashtags either.
+func Parse(text string) []Entity {
+	runes := []rune(text)
+	entities := []Entity{}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != '@' && r != '#' {
+			continue
+		}
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		if r == '@' {
+			for end < len(runes) && (isWordRune(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			// a trailing dot ends the sentence, not the username
+			for end > i+1 && runes[end-1] == '.' {
+				end--
+			}
+		} else {
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+		}
+		if end == i+1 {
+			continue
+		}
+
+		name := string(runes[i+1 : end])
+		if r == '@' {
+			entities = append(entities, Entity{Type: TYPE_MENTION, Text: name, Start: i, End: end})
+		} else if !isNumeric(name) && end-i-1 <= MAX_TAG_LENGTH {
+			entities = append(entities, Entity{Type: TYPE_HASHTAG, Text: strings.ToLower(name), Start: i, End: end})
+		}
+		i = end - 1
+	}
+	return entities
+}
+
+// Tags returns the distinct hashtags of entities.
+func Tags(entities []Entity) []string {
+	return distinct(entities, TYPE_HASHTAG)
+}
+
+// Usernames returns the distinct mentioned usernames of entities.
+func Usernames(entities []Entity) []string {
+	return distinct(entities, TYPE_MENTION)
+}
+
+// NormalizeTag turns user input such as "#Go" into the stored form "go".
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func distinct(entities []Entity, kind string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, e := range entities {
+		key := strings.ToLower(e.Text)
+		if e.Type != kind || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, e.Text)
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
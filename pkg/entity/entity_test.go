@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Entity
+	}{
+		{"hi @Alice, see #Go", []Entity{
+			{Type: TYPE_MENTION, Text: "Alice", Start: 3, End: 9},
+			{Type: TYPE_HASHTAG, Text: "go", Start: 15, End: 18},
+		}},
+		{"mail me@example.com about C# or #123", []Entity{}},
+		{"thanks @bob.", []Entity{{Type: TYPE_MENTION, Text: "bob", Start: 7, End: 11}}},
+		{"café #crème", []Entity{{Type: TYPE_HASHTAG, Text: "crème", Start: 5, End: 11}}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseTagLength(t *testing.T) {
+	longest := strings.Repeat("é", MAX_TAG_LENGTH)
+	if got := Parse("#" + longest); len(got) != 1 || got[0].Text != longest {
+		t.Errorf("got %+v, want the %d rune hashtag", got, MAX_TAG_LENGTH)
+	}
+	// nothing inside an overlong hashtag is parsed either
+	if got := Parse("#" + longest + "x#y @z"); len(got) != 1 || got[0].Type != TYPE_MENTION {
+		t.Errorf("got %+v, want only the mention", got)
+	}
+}
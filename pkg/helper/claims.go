@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrTokenInvalid     = errors.New("invalid token")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrTokenIssuer      = errors.New("token issuer is not accepted")
+	ErrTokenAudience    = errors.New("token audience is not accepted")
+	ErrTokenSubject     = errors.New("token type is not accepted here")
+)
+
+// ClaimPolicy is what every MyGram token must agree with besides its
+// signature. Leeway absorbs clock skew between issuer and verifier.
+type ClaimPolicy struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+func (p ClaimPolicy) Verify(claim jwt.MapClaims, subject string, now time.Time) error {
+	if !claim.VerifyIssuer(p.Issuer, true) {
+		return ErrTokenIssuer
+	}
+	if !claim.VerifyAudience(p.Audience, true) {
+		return ErrTokenAudience
+	}
+	if sub, _ := claim["sub"].(string); sub != subject {
+		return ErrTokenSubject
+	}
+	if !claim.VerifyExpiresAt(now.Add(-p.Leeway).Unix(), true) {
+		return ErrTokenExpired
+	}
+	if !claim.VerifyNotBefore(now.Add(p.Leeway).Unix(), false) {
+		return ErrTokenNotYetValid
+	}
+	if !claim.VerifyIssuedAt(now.Add(p.Leeway).Unix(), false) {
+		return ErrTokenNotYetValid
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package helper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testAccessSubject  = "access-token"
+	testRefreshSubject = "refresh-token"
+)
+
+var testPolicy = ClaimPolicy{Issuer: "mygram", Audience: "mygram-api", Leeway: 30 * time.Second}
+
+// unix is a NumericDate as it comes out of a parsed token.
+func unix(t time.Time) float64 {
+	return float64(t.Unix())
+}
+
+// testClaim is a valid access claim at now.
+func testClaim(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":     "mygram",
+		"aud":     "mygram-api",
+		"sub":     testAccessSubject,
+		"iat":     unix(now),
+		"nbf":     unix(now),
+		"exp":     unix(now.Add(15 * time.Minute)),
+		"user_id": 7,
+	}
+}
+
+func TestClaimPolicyVerify(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		change func(jwt.MapClaims)
+		want   error
+	}{
+		{"valid", func(c jwt.MapClaims) {}, nil},
+		{"audience list", func(c jwt.MapClaims) { c["aud"] = []any{"other", "mygram-api"} }, nil},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "someone-else" }, ErrTokenIssuer},
+		{"no issuer", func(c jwt.MapClaims) { delete(c, "iss") }, ErrTokenIssuer},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-api" }, ErrTokenAudience},
+		{"no audience", func(c jwt.MapClaims) { delete(c, "aud") }, ErrTokenAudience},
+		{"refresh token used as access token", func(c jwt.MapClaims) { c["sub"] = testRefreshSubject }, ErrTokenSubject},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }, ErrTokenSubject},
+		{"expired within the leeway", func(c jwt.MapClaims) { c["exp"] = unix(now.Add(-testPolicy.Leeway + time.Second)) }, nil},
+		{"expired at the leeway", func(c jwt.MapClaims) { c["exp"] = unix(now.Add(-testPolicy.Leeway)) }, ErrTokenExpired},
+		{"expired past the leeway", func(c jwt.MapClaims) { c["exp"] = unix(now.Add(-testPolicy.Leeway - time.Second)) }, ErrTokenExpired},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }, ErrTokenExpired},
+		{"not before within the leeway", func(c jwt.MapClaims) { c["nbf"] = unix(now.Add(testPolicy.Leeway)) }, nil},
+		{"not before in the future", func(c jwt.MapClaims) { c["nbf"] = unix(now.Add(testPolicy.Leeway + time.Second)) }, ErrTokenNotYetValid},
+		{"no not before", func(c jwt.MapClaims) { delete(c, "nbf") }, nil},
+		{"issued in the future", func(c jwt.MapClaims) { c["iat"] = unix(now.Add(time.Hour)) }, ErrTokenNotYetValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := testClaim(now)
+			tt.change(claim)
+			if err := testPolicy.Verify(claim, testAccessSubject, now); err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func testKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keys, err := NewKeySet("hs",
+		SigningKey{ID: "hs", Algorithm: ALG_HS512, Secret: []byte(strings.Repeat("s", MIN_SECRET_BYTES))},
+		SigningKey{ID: "ec", Algorithm: ALG_ES256, Private: ec, Public: &ec.PublicKey},
+	)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return keys
+}
+
+// sign signs claim with the key kid of keys but the given method, the way
+// a forger controlling the header would.
+func sign(t *testing.T, keys *KeySet, kid string, method jwt.SigningMethod, claim jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claim)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	var key any = []byte(strings.Repeat("s", MIN_SECRET_BYTES))
+	if k, ok := keys.keys[kid]; ok && k.Private != nil && method.Alg() == k.Algorithm {
+		key = k.Private
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestKeySetParseClaim(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeySet(t)
+	now := time.Now()
+	valid := testClaim(now)
+	refresh := testClaim(now)
+	refresh["sub"] = testRefreshSubject
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"active key", sign(t, keys, "hs", jwt.SigningMethodHS512, valid), nil},
+		{"older key", sign(t, keys, "ec", jwt.SigningMethodES256, valid), nil},
+		{"no kid falls back to the active key", sign(t, keys, "", jwt.SigningMethodHS512, valid), nil},
+		{"unknown kid", sign(t, keys, "retired", jwt.SigningMethodHS512, valid), ErrTokenInvalid},
+		{"alg not matching the key", sign(t, keys, "ec", jwt.SigningMethodHS512, valid), ErrTokenInvalid},
+		{"weaker HMAC alg for the HMAC key", sign(t, keys, "hs", jwt.SigningMethodHS256, valid), ErrTokenInvalid},
+		{"tampered signature", sign(t, keys, "hs", jwt.SigningMethodHS512, valid) + "x", ErrTokenInvalid},
+		{"refresh token used as access token", sign(t, keys, "hs", jwt.SigningMethodHS512, refresh), ErrTokenSubject},
+		{"garbage", "not.a.token", ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct {
+				UserID uint64 `json:"user_id"`
+			}
+			err := keys.ParseClaim(ctx, tt.token, testAccessSubject, testPolicy, &out)
+			if err != tt.want {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err == nil && out.UserID != 7 {
+				t.Errorf("decoded user %d, want 7", out.UserID)
+			}
+		})
+	}
+}
+
+func TestKeySetParseClaimChecksPolicy(t *testing.T) {
+	keys := testKeySet(t)
+	claim := testClaim(time.Now())
+	claim["exp"] = unix(time.Now().Add(-time.Hour))
+
+	var out jwt.MapClaims
+	if err := keys.ParseClaim(context.Background(), sign(t, keys, "hs", jwt.SigningMethodHS512, claim), testAccessSubject, testPolicy, &out); err != ErrTokenExpired {
+		t.Errorf("got %v, want ErrTokenExpired", err)
+	}
+}
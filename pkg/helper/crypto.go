@@ -1,66 +1,45 @@
 package helper
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"log"
 
-	"github.com/dgrijalva/jwt-go"
-	"golang.org/x/crypto/bcrypt"
-)
+	"github.com/geedotrar/mygram/pkg/logger"
 
-const (
-	SECRET_JWT = "mysecretjwtdontsharethistoanyoneelse"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func GenerateToken(claim any) (token string, err error) {
-	jwtClaim := jwt.MapClaims{}
+func DecodeClaim(ctx context.Context, claim jwt.MapClaims, out any) (err error) {
 	b, err := json.Marshal(claim)
 	if err != nil {
-		log.Println("cannot marshal claim payload")
+		logger.FromContext(ctx).Error("cannot marshal jwt claim", "error", err)
 		return
 	}
-	err = json.Unmarshal(b, &jwtClaim)
+	err = json.Unmarshal(b, out)
 	if err != nil {
-		log.Println("cannot mapping claim to jwt claim")
-		return
-	}
-	// prepare
-	parseToken := jwt.NewWithClaims(jwt.SigningMethodHS512, jwtClaim)
-	// generate token
-	token, err = parseToken.SignedString([]byte(SECRET_JWT))
-	if err != nil {
-		log.Println("cannot generate token", err.Error())
+		logger.FromContext(ctx).Error("cannot map jwt claim to claim payload", "error", err)
 		return
 	}
 	return
 }
 
-func ValidateToken(token string) (claim jwt.MapClaims, err error) {
-	jwtToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-
-		return []byte(SECRET_JWT), nil
-	})
-	if err != nil {
-		log.Println("error validating jwt token", err.Error())
-		return
-	}
-
-	// translate claim
-	claim, ok := jwtToken.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Println("error translate claim")
-		return
+// GenerateRandomID returns a 128-bit random hex string suitable for token IDs.
+func GenerateRandomID(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		logger.FromContext(ctx).Error("cannot generate random id", "error", err)
+		return "", err
 	}
-	return
+	return hex.EncodeToString(b), nil
 }
 
-func GenerateHash(in string) (out string, err error) {
+func GenerateHash(ctx context.Context, in string) (out string, err error) {
 	outByte, err := bcrypt.GenerateFromPassword([]byte(in), bcrypt.DefaultCost)
 	if err != nil {
-		log.Println("error generate hash password", err.Error())
+		logger.FromContext(ctx).Error("cannot hash password", "error", err)
 		return
 	}
 	return string(outByte), err
@@ -0,0 +1,311 @@
+package helper
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/geedotrar/mygram/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	ALG_HS512 = "HS512"
+	ALG_RS256 = "RS256"
+	ALG_ES256 = "ES256"
+	ALG_EDDSA = "EdDSA"
+)
+
+// MIN_SECRET_BYTES is the shortest HS512 secret accepted, so a secret is
+// never weaker than the asymmetric keys.
+const MIN_SECRET_BYTES = 32
+
+// SigningKey is one entry of a KeySet. HMAC keys only carry Secret; the
+// asymmetric ones carry Public and, unless they are verify-only, Private.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Secret    []byte
+	Private   crypto.PrivateKey
+	Public    crypto.PublicKey
+}
+
+// KeySet holds every key a token may be verified with. Only the active key
+// signs new tokens, so old keys can stay around until their tokens expire.
+type KeySet struct {
+	active string
+	keys   map[string]SigningKey
+	order  []string
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeySet(active string, keys ...SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	k := &KeySet{keys: map[string]SigningKey{}}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing key id cannot be empty")
+		}
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		if signingMethod(key.Algorithm) == nil {
+			return nil, fmt.Errorf("signing key %q: unsupported algorithm %q", key.ID, key.Algorithm)
+		}
+		k.keys[key.ID] = key
+		k.order = append(k.order, key.ID)
+	}
+	if active == "" {
+		active = keys[0].ID
+	}
+	key, ok := k.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", active)
+	}
+	if key.Secret == nil && key.Private == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", active)
+	}
+	k.active = active
+	return k, nil
+}
+
+// LoadKeySet builds the key set from entries, a comma separated list of
+// kid:ALG:path entries where path points to a PEM key (or, for HS512, a file
+// holding the raw secret). PEM public keys are accepted as verify-only keys.
+// active picks the signing key and defaults to the first entry. Without
+// entries a single HS512 key is built from secret, which is held to the
+// same minimum length.
+func LoadKeySet(entries string, secret string, active string) (*KeySet, error) {
+	entries = strings.TrimSpace(entries)
+	if entries == "" {
+		if secret == "" {
+			return nil, errors.New("either JWT_KEYS or JWT_SECRET must be set")
+		}
+		if len(secret) < MIN_SECRET_BYTES {
+			return nil, fmt.Errorf("JWT_SECRET must be at least %d bytes", MIN_SECRET_BYTES)
+		}
+		return NewKeySet("default", SigningKey{ID: "default", Algorithm: ALG_HS512, Secret: []byte(secret)})
+	}
+
+	keys := []SigningKey{}
+	for _, entry := range strings.Split(entries, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q, expected kid:ALG:path", entry)
+		}
+		b, err := os.ReadFile(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", parts[0], err)
+		}
+		key, err := ParseSigningKey(parts[0], parts[1], b)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(active, keys...)
+}
+
+// ParseSigningKey turns raw key material into a SigningKey, checking that the
+// key type matches the algorithm.
+func ParseSigningKey(id string, alg string, material []byte) (SigningKey, error) {
+	key := SigningKey{ID: id, Algorithm: alg}
+	if alg == ALG_HS512 {
+		secret := []byte(strings.TrimSpace(string(material)))
+		if len(secret) < MIN_SECRET_BYTES {
+			return SigningKey{}, fmt.Errorf("signing key %q: HS512 secret must be at least %d bytes", id, MIN_SECRET_BYTES)
+		}
+		key.Secret = secret
+		return key, nil
+	}
+
+	block, _ := pem.Decode(material)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("signing key %q: no PEM block found", id)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("signing key %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Private, key.Public = k, k.Public()
+	default:
+		key.Public = k
+	}
+
+	ok := false
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		ok = alg == ALG_RS256
+	case *ecdsa.PublicKey:
+		ok = alg == ALG_ES256 && pub.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		ok = alg == ALG_EDDSA
+	}
+	if !ok {
+		return SigningKey{}, fmt.Errorf("signing key %q: key type does not match algorithm %s", id, alg)
+	}
+	return key, nil
+}
+
+func (k *KeySet) GenerateToken(ctx context.Context, claim any) (token string, err error) {
+	jwtClaim := jwt.MapClaims{}
+	b, err := json.Marshal(claim)
+	if err != nil {
+		logger.FromContext(ctx).Error("cannot marshal claim payload", "error", err)
+		return
+	}
+	err = json.Unmarshal(b, &jwtClaim)
+	if err != nil {
+		logger.FromContext(ctx).Error("cannot map claim to jwt claim", "error", err)
+		return
+	}
+
+	key := k.keys[k.active]
+	parseToken := jwt.NewWithClaims(signingMethod(key.Algorithm), jwtClaim)
+	parseToken.Header["kid"] = key.ID
+
+	if key.Secret != nil {
+		token, err = parseToken.SignedString(key.Secret)
+	} else {
+		token, err = parseToken.SignedString(key.Private)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("cannot generate token", "kid", key.ID, "error", err)
+		return
+	}
+	return
+}
+
+// ParseClaim verifies the token signature, checks it against the policy and
+// the expected subject, then decodes its payload into out.
+func (k *KeySet) ParseClaim(ctx context.Context, token string, subject string, policy ClaimPolicy, out any) error {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	jwtToken, err := parser.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = k.active
+		}
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// the key decides the algorithm, never the token header
+		if t.Method.Alg() != key.Algorithm {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		if key.Secret != nil {
+			return key.Secret, nil
+		}
+		return key.Public, nil
+	})
+	if err != nil {
+		logger.FromContext(ctx).Warn("invalid jwt token", "error", err)
+		return ErrTokenInvalid
+	}
+
+	claim, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok {
+		logger.FromContext(ctx).Warn("cannot translate jwt claim")
+		return ErrTokenInvalid
+	}
+	if err := policy.Verify(claim, subject, time.Now()); err != nil {
+		return err
+	}
+	return DecodeClaim(ctx, claim, out)
+}
+
+// JWKS returns the public half of every asymmetric key. HMAC secrets are
+// never published.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range k.order {
+		key := k.keys[id]
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case ALG_HS512:
+		return jwt.SigningMethodHS512
+	case ALG_RS256:
+		return jwt.SigningMethodRS256
+	case ALG_ES256:
+		return jwt.SigningMethodES256
+	case ALG_EDDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return nil
+}
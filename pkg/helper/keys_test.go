@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeySetSecret(t *testing.T) {
+	if _, err := LoadKeySet("", "", ""); err == nil {
+		t.Error("got no error without JWT_KEYS nor JWT_SECRET")
+	}
+	if _, err := LoadKeySet("", "secret", ""); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Errorf("got %v for a short JWT_SECRET, want a length error", err)
+	}
+	keys, err := LoadKeySet("", strings.Repeat("s", MIN_SECRET_BYTES), "")
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	if key := keys.keys[keys.active]; key.Algorithm != ALG_HS512 || len(key.Secret) != MIN_SECRET_BYTES {
+		t.Errorf("got %s key of %d bytes", key.Algorithm, len(key.Secret))
+	}
+}
+
+func TestLoadKeySetSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short")
+	long := filepath.Join(dir, "long")
+	os.WriteFile(short, []byte("secret\n"), 0o600)
+	os.WriteFile(long, []byte(strings.Repeat("s", MIN_SECRET_BYTES)+"\n"), 0o600)
+
+	if _, err := LoadKeySet("k1:HS512:"+short, "", ""); err == nil {
+		t.Error("got no error for a short HS512 secret file")
+	}
+	// JWT_SECRET is ignored once JWT_KEYS is set
+	if _, err := LoadKeySet("k1:HS512:"+long, "secret", ""); err != nil {
+		t.Errorf("LoadKeySet: %v", err)
+	}
+}
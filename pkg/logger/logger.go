@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+
+	REDACTED = "[REDACTED]"
+)
+
+// sensitiveKeys are the attribute names, compared in lowercase, whose
+// values never reach the output. Any key mentioning a password is redacted
+// too.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"secret":        true,
+}
+
+type contextKey struct{}
+
+// New returns a logger writing to w in format, json or text, from level on.
+// Sensitive attributes are redacted, including the fields of logged
+// structs, which are broken down by their json names.
+func New(w io.Writer, format string, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	if format == FORMAT_TEXT {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// NewContext returns a copy of ctx carrying l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds args to every line.
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, REDACTED)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		if v, ok := structValue(a.Value.Any()); ok {
+			a.Value = v
+		}
+	}
+	return a
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	return sensitiveKeys[key] || strings.Contains(key, "password")
+}
+
+// structValue turns a struct into a group of its exported fields, so that
+// redact gets to see each of them. Types that know how to log or print
+// themselves are left alone.
+func structValue(value any) (slog.Value, bool) {
+	switch value.(type) {
+	case slog.LogValuer, error, fmt.Stringer, json.Marshaler, encoding.TextMarshaler:
+		return slog.Value{}, false
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return slog.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return slog.Value{}, false
+	}
+	return slog.GroupValue(fields(v)...), true
+}
+
+func fields(v reflect.Value) []slog.Attr {
+	attrs := []slog.Attr{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// like encoding/json, promote the fields of embedded structs even
+		// when their type is unexported
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			attrs = append(attrs, fields(v.Field(i))...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		attrs = append(attrs, slog.Any(name, v.Field(i).Interface()))
+	}
+	return attrs
+}
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type session struct {
+	ID           uint64 `json:"id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Hash         string `json:"-"`
+	internal     string
+}
+
+type signUp struct {
+	credentials
+	Username string   `json:"username"`
+	Session  *session `json:"session"`
+	Token    string
+}
+
+func TestRedact(t *testing.T) {
+	const secret = "hunter2"
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "top level keys",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "Authorization", "Bearer "+secret, "token", secret, "new_password", secret, "user_id", 7)
+			},
+			want: map[string]any{"Authorization": REDACTED, "token": REDACTED, "new_password": REDACTED, "user_id": float64(7)},
+		},
+		{
+			name: "struct",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "user", signUp{
+					credentials: credentials{Email: "a@example.com", Password: secret},
+					Username:    "alice",
+					Session:     &session{ID: 3, AccessToken: secret, RefreshToken: secret, Hash: secret, internal: secret},
+					Token:       secret,
+				})
+			},
+			want: map[string]any{"user": map[string]any{
+				"email":    "a@example.com",
+				"password": REDACTED,
+				"username": "alice",
+				"session":  map[string]any{"id": float64(3), "access_token": REDACTED, "refresh_token": REDACTED},
+				"Token":    REDACTED,
+			}},
+		},
+		{
+			name: "pointer to struct",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "login", &credentials{Email: "a@example.com", Password: secret})
+			},
+			want: map[string]any{"login": map[string]any{"email": "a@example.com", "password": REDACTED}},
+		},
+		{
+			name: "groups",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("request",
+					slog.String("method", "POST"),
+					slog.Group("headers", slog.String("Cookie", "sid="+secret), slog.String("Set-Cookie", "sid="+secret), slog.String("Accept", "*/*")),
+				))
+			},
+			want: map[string]any{"request": map[string]any{
+				"method":  "POST",
+				"headers": map[string]any{"Cookie": REDACTED, "Set-Cookie": REDACTED, "Accept": "*/*"},
+			}},
+		},
+		{
+			name: "logger attrs",
+			log: func(l *slog.Logger) {
+				l.With("secret", secret).WithGroup("db").Info("msg", "PASSWORD", secret, "host", "localhost")
+			},
+			want: map[string]any{"secret": REDACTED, "db": map[string]any{"PASSWORD": REDACTED, "host": "localhost"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(New(&buf, FORMAT_JSON, "info"))
+
+			if strings.Contains(buf.String(), secret) {
+				t.Errorf("secret leaked: %s", buf.String())
+			}
+			line := map[string]any{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("cannot decode %q: %v", buf.String(), err)
+			}
+			for _, key := range []string{"time", "level", "msg"} {
+				delete(line, key)
+			}
+			got, _ := json.Marshal(line)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, FORMAT_TEXT, "info").Info("msg", "login", credentials{Email: "a@example.com", Password: "hunter2"}, "refresh_token", "hunter2")
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("secret leaked: %s", out)
+	}
+	if !strings.Contains(out, "login.password="+REDACTED) || !strings.Contains(out, "refresh_token="+REDACTED) {
+		t.Errorf("got %s, want the redacted attributes", out)
+	}
+}
@@ -0,0 +1,127 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_LIMIT = 20
+	MAX_LIMIT     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 1 and %d", MAX_LIMIT)
+)
+
+// Cursor is the keyset position of the last row of a page. Lists are
+// ordered newest first by (created_at, id), so it is all we need to resume.
+// Search results are ordered by (rank, id) instead and set Rank.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Rank      float64   `json:"r,omitempty"`
+	ID        uint64    `json:"i"`
+}
+
+// Params is a page request: at most Limit rows after the After cursor.
+type Params struct {
+	Limit int
+	After *Cursor
+}
+
+// Page is the response envelope every list endpoint returns.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewParams parses the limit and cursor query values. Both are optional.
+func NewParams(limit string, cursor string) (Params, error) {
+	params := Params{Limit: DEFAULT_LIMIT}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MAX_LIMIT {
+			return Params{}, ErrInvalidLimit
+		}
+		params.Limit = n
+	}
+	if cursor != "" {
+		c, err := Decode(cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = &c
+	}
+	return params, nil
+}
+
+// Encode turns the cursor into an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode parses a token produced by Encode.
+func Decode(token string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	c := Cursor{}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Scope orders the query newest first, skips everything up to the cursor
+// and fetches one extra row so NewPage can tell whether more remain. table
+// qualifies the columns when the query joins other tables.
+func Scope(params Params, table string) func(*gorm.DB) *gorm.DB {
+	createdAt, id := table+".created_at", table+".id"
+	return func(db *gorm.DB) *gorm.DB {
+		if params.After != nil {
+			db = db.Where(fmt.Sprintf("(%s, %s) < (?, ?)", createdAt, id), params.After.CreatedAt, params.After.ID)
+		}
+		return db.
+			Order(createdAt + " DESC").
+			Order(id + " DESC").
+			Limit(params.Limit + 1)
+	}
+}
+
+// RankScope is Scope for search results, ordered by descending rank. rank
+// and id name the columns to order by.
+func RankScope(params Params, rank string, id string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.After != nil {
+			db = db.Where(fmt.Sprintf("(%s, %s) < (?, ?)", rank, id), params.After.Rank, params.After.ID)
+		}
+		return db.
+			Order(rank + " DESC").
+			Order(id + " DESC").
+			Limit(params.Limit + 1)
+	}
+}
+
+// NewPage trims the extra row fetched by Scope and sets the next cursor.
+func NewPage[T any](rows []T, params Params, cursorOf func(T) Cursor) Page[T] {
+	page := Page[T]{Data: rows}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(rows) > params.Limit {
+		page.Data = rows[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = cursorOf(page.Data[len(page.Data)-1]).Encode()
+	}
+	return page
+}
@@ -0,0 +1,169 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 15, 123456789, time.UTC), ID: 42},
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 15, 0, time.FixedZone("WIB", 7*3600)), ID: 1},
+		{Rank: 0.0607927, ID: 18446744073709551615},
+	}
+	for _, c := range cursors {
+		token := c.Encode()
+		if strings.ContainsAny(token, "+/=") {
+			t.Errorf("token %q is not URL-safe", token)
+		}
+		got, err := Decode(token)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", token, err)
+		}
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.Rank != c.Rank || got.ID != c.ID {
+			t.Errorf("got %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	token := Cursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 42}.Encode()
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	tests := map[string]string{
+		"empty":            "",
+		"not base64":       "not a cursor!",
+		"padded":           base64.URLEncoding.EncodeToString([]byte(`{"t":"2024-05-01T00:00:00Z","i":42}`)),
+		"truncated":        token[:len(token)-4],
+		"trailing garbage": token + "x",
+		"not json":         encode("42"),
+		"no id":            encode(`{"t":"2024-05-01T00:00:00Z"}`),
+		"zero id":          encode(`{"t":"2024-05-01T00:00:00Z","i":0}`),
+		"negative id":      encode(`{"t":"2024-05-01T00:00:00Z","i":-1}`),
+		"id as string":     encode(`{"t":"2024-05-01T00:00:00Z","i":"42"}`),
+		"bad time":         encode(`{"t":"yesterday","i":42}`),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if c, err := Decode(token); err != ErrInvalidCursor {
+				t.Errorf("got %+v, %v, want ErrInvalidCursor", c, err)
+			}
+		})
+	}
+}
+
+func TestNewParams(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 42}
+	tests := []struct {
+		limit  string
+		cursor string
+		want   Params
+		err    error
+	}{
+		{"", "", Params{Limit: DEFAULT_LIMIT}, nil},
+		{"1", "", Params{Limit: 1}, nil},
+		{"100", cursor.Encode(), Params{Limit: MAX_LIMIT, After: &cursor}, nil},
+		{"0", "", Params{}, ErrInvalidLimit},
+		{"101", "", Params{}, ErrInvalidLimit},
+		{"-5", "", Params{}, ErrInvalidLimit},
+		{"ten", "", Params{}, ErrInvalidLimit},
+		{"10", "bogus", Params{}, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		got, err := NewParams(tt.limit, tt.cursor)
+		if err != tt.err {
+			t.Errorf("NewParams(%q, %q): got error %v, want %v", tt.limit, tt.cursor, err, tt.err)
+			continue
+		}
+		if got.Limit != tt.want.Limit || (got.After == nil) != (tt.want.After == nil) ||
+			(got.After != nil && (got.After.ID != tt.want.After.ID || !got.After.CreatedAt.Equal(tt.want.After.CreatedAt))) {
+			t.Errorf("NewParams(%q, %q) = %+v, want %+v", tt.limit, tt.cursor, got, tt.want)
+		}
+	}
+}
+
+type row struct {
+	ID        uint64
+	CreatedAt time.Time
+}
+
+func rows(n int) []row {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rs := make([]row, n)
+	for i := range rs {
+		// newest first, as Scope orders them
+		rs[i] = row{ID: uint64(n - i), CreatedAt: start.Add(time.Duration(n-i) * time.Minute)}
+	}
+	return rs
+}
+
+func cursorOf(r row) Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+func TestNewPage(t *testing.T) {
+	params := Params{Limit: 3}
+	tests := []struct {
+		name    string
+		rows    []row
+		ids     []uint64
+		hasMore bool
+	}{
+		{"nil", nil, []uint64{}, false},
+		{"empty", []row{}, []uint64{}, false},
+		{"partial", rows(2), []uint64{2, 1}, false},
+		{"exactly the limit", rows(3), []uint64{3, 2, 1}, false},
+		{"limit plus one", rows(4), []uint64{4, 3, 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewPage(tt.rows, params, cursorOf)
+			if page.Data == nil {
+				t.Fatal("Data is nil, want an empty list")
+			}
+			ids := []uint64{}
+			for _, r := range page.Data {
+				ids = append(ids, r.ID)
+			}
+			if b, want := mustJSON(t, ids), mustJSON(t, tt.ids); b != want {
+				t.Errorf("got rows %s, want %s", b, want)
+			}
+			if page.HasMore != tt.hasMore {
+				t.Errorf("got HasMore %v, want %v", page.HasMore, tt.hasMore)
+			}
+			if !tt.hasMore {
+				if page.NextCursor != "" {
+					t.Errorf("got cursor %q on the last page", page.NextCursor)
+				}
+				return
+			}
+			next, err := Decode(page.NextCursor)
+			if err != nil {
+				t.Fatalf("Decode(NextCursor): %v", err)
+			}
+			last := page.Data[len(page.Data)-1]
+			if next.ID != last.ID || !next.CreatedAt.Equal(last.CreatedAt) {
+				t.Errorf("next cursor %+v, want the last row returned %+v", next, last)
+			}
+		})
+	}
+}
+
+func TestNewPageJSON(t *testing.T) {
+	if got := mustJSON(t, NewPage[row](nil, Params{Limit: 20}, cursorOf)); got != `{"data":[],"has_more":false}` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
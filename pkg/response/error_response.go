@@ -1,6 +1,14 @@
 package response
 
 type ErrorResponse struct {
-	Message string   `json:"message"`
-	Errors  []string `json:"errors,omitempty"`
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Errors  []string     `json:"errors,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError points at an invalid field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }